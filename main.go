@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/iulianclita/logy/parser"
 	"github.com/spf13/cobra"
@@ -10,15 +11,8 @@ import (
 
 func main() {
 	// Flag placeholders
-	var (
-		text      string
-		filter    string
-		lines     int
-		page      int
-		ext       string
-		withRegex bool
-		noColor   bool
-	)
+	var opts parser.Options
+	var profile string
 	// Define command
 	appCmd := &cobra.Command{
 		Use:   "logy /path/to/file",
@@ -34,20 +28,208 @@ func main() {
 				return
 			}
 			path := args[0]
+			// In batch mode an explicit page number means only that page is printed
+			// Exports and the navigator only start from it
+			if opts.Pages == "" && opts.Export == "" && cmd.Flags().Changed("page") && parser.BatchMode(opts) {
+				opts.Pages = strconv.Itoa(opts.Page)
+			}
 			// Create parser object
-			p := parser.New(path, text, filter, lines, page, noColor, withRegex, ext)
+			p := parser.New(path, opts)
 			// Start parsing the given file
 			p.Parse()
 		},
 	}
+	// Config files and profiles fill the flags that are not given on the command line
+	// This runs for every command, the keys of the other commands are skipped
+	appCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		parser.ApplyConfig(cmd, profile)
+	}
+	// Report placeholders
+	var reportOut string
+	var reportMaxLines int
+	// Define report command
+	reportCmd := &cobra.Command{
+		Use:   "report /path/to/file",
+		Short: "Write a self contained HTML report with the matching lines",
+		Long:  `Write a self contained HTML report with the stats, a timeline of the matches and the matching lines`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Create parser object
+			p := parser.New(args[0], opts)
+			// Write the report
+			if err := p.Report(reportOut, reportMaxLines); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		},
+	}
+	reportCmd.Flags().StringVar(&reportOut, "out", "report.html", "Path of the HTML report")
+	reportCmd.Flags().IntVar(&reportMaxLines, "max-lines", 10000, "Maximum number of matching lines per file in the report (0 means no limit)")
+	appCmd.AddCommand(reportCmd)
+	// Server placeholders
+	var serveAddr string
+	// Define serve command
+	serveCmd := &cobra.Command{
+		Use:   "serve /path/to/file",
+		Short: "Browse the files from a web browser",
+		Long:  `Start a local HTTP server with a JSON API and a web viewer to browse and search the files`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Create parser object
+			p := parser.New(args[0], opts)
+			// Serve the files until the process is stopped
+			p.Serve(serveAddr)
+		},
+	}
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "Address the server listens on")
+	appCmd.AddCommand(serveCmd)
+	// Patterns placeholders
+	var patternsTop int
+	var patternsSimilarity float64
+	// Define patterns command
+	patternsCmd := &cobra.Command{
+		Use:   "patterns /path/to/file",
+		Short: "Print the most common message patterns",
+		Long:  `Group the matching lines into message patterns and print the most common ones with their counts`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Create parser object
+			p := parser.New(args[0], opts)
+			// Print the top patterns
+			p.Patterns(patternsTop, patternsSimilarity)
+		},
+	}
+	patternsCmd.Flags().IntVar(&patternsTop, "top", 10, "Number of patterns to print (0 means all)")
+	patternsCmd.Flags().Float64Var(&patternsSimilarity, "similarity", 0.5, "Minimum share of equal words for lines to share a pattern (0-1)")
+	appCmd.AddCommand(patternsCmd)
+	// Aggregation placeholders
+	var aggOpts parser.AggOptions
+	// Define agg command
+	aggCmd := &cobra.Command{
+		Use:   "agg /path/to/file",
+		Short: "Group structured lines by fields and compute metrics",
+		Long:  `Group JSON, logfmt or nginx lines by fields and print metrics like count, avg or percentiles for every group`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Create parser object
+			p := parser.New(args[0], opts)
+			// Print the aggregation table
+			p.Aggregate(aggOpts)
+		},
+	}
+	aggCmd.Flags().StringSliceVar(&aggOpts.GroupBy, "group-by", nil, "Fields to group the lines by (nested JSON fields are separated by dots)")
+	aggCmd.Flags().StringArrayVar(&aggOpts.Metrics, "metric", nil, "Metric to compute: count, sum(field), avg(field), min(field), max(field) or pNN(field) (default count)")
+	aggCmd.Flags().StringVar(&aggOpts.Format, "format", "auto", "Format of the lines (auto/json/logfmt/nginx)")
+	aggCmd.Flags().StringVar(&aggOpts.Sort, "sort", "", "Metric to sort the groups by (default the first metric)")
+	aggCmd.Flags().IntVar(&aggOpts.Top, "top", 0, "Number of groups to print (0 means all)")
+	appCmd.AddCommand(aggCmd)
+	// Diff placeholders
+	var diffUnified int
+	// Define diff command
+	diffCmd := &cobra.Command{
+		Use:   "diff /path/to/a /path/to/b",
+		Short: "Compare 2 files ignoring timestamps, PIDs, UUIDs and durations",
+		Long:  `Show the unified diff of 2 files after masking the values that change from run to run, like timestamps, PIDs, UUIDs and durations`,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Browse the diff of both files
+			parser.Diff(args[0], args[1], opts, diffUnified)
+		},
+	}
+	diffCmd.Flags().IntVarP(&diffUnified, "unified", "U", 3, "Number of context lines around every change")
+	appCmd.AddCommand(diffCmd)
+	// Saved searches placeholders
+	var searchDescription string
+	var searchProject bool
+	// Define search commands
+	searchCmd := &cobra.Command{
+		Use:   "search",
+		Short: "Save, list and run named filter queries",
+		Long:  `Save regex filter queries under a name, list them and run them across files`,
+	}
+	searchSaveCmd := &cobra.Command{
+		Use:   "save name query",
+		Short: "Save a regex query under a name",
+		Long:  `Save a regex query under a name in ~/.config/logy/searches.yaml (or .logy-searches.yaml with --project)`,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			parser.SaveSearch(args[0], args[1], searchDescription, searchProject)
+		},
+	}
+	searchSaveCmd.Flags().StringVar(&searchDescription, "description", "", "What the search is looking for")
+	searchListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the saved searches",
+		Long:  `List the saved searches of the user and of the project`,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			parser.ListSearches()
+		},
+	}
+	searchDeleteCmd := &cobra.Command{
+		Use:   "delete name",
+		Short: "Delete a saved search",
+		Long:  `Delete a saved search from ~/.config/logy/searches.yaml (or .logy-searches.yaml with --project)`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			parser.DeleteSearch(args[0], searchProject)
+		},
+	}
+	searchRunCmd := &cobra.Command{
+		Use:   "run name[,name...] /path/to/file",
+		Short: "Run saved searches and print the matches of every file",
+		Long:  `Run one or more saved searches (separated by commas) on the files and print the stats of every search`,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Create parser object
+			p := parser.New(args[1], opts)
+			// Print the stats of every search
+			p.RunSearches(args[0])
+		},
+	}
+	searchCmd.PersistentFlags().BoolVar(&searchProject, "project", false, "Use the project searches file (.logy-searches.yaml) instead of the user one")
+	searchCmd.AddCommand(searchSaveCmd, searchListCmd, searchDeleteCmd, searchRunCmd)
+	appCmd.AddCommand(searchCmd)
 	// Parse flags
-	appCmd.PersistentFlags().StringVarP(&text, "text", "t", "plain", "Text type to parse (plain/json)")
-	appCmd.PersistentFlags().StringVarP(&filter, "filter", "f", "", "Text to filter by")
-	appCmd.PersistentFlags().IntVarP(&lines, "lines", "l", 50, "Number of lines per page")
-	appCmd.PersistentFlags().IntVarP(&page, "page", "p", 1, "Current page number")
-	appCmd.PersistentFlags().StringVarP(&ext, "ext", "e", "", "Accepted file extensions to search in folder")
-	appCmd.PersistentFlags().BoolVar(&withRegex, "with-regex", false, "Enable regex support")
-	appCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable color output")
+	appCmd.PersistentFlags().StringVarP(&opts.Text, "text", "t", "plain", "Text type to parse (plain/json/xml/yaml, yaml is colored line by line)")
+	appCmd.PersistentFlags().StringVarP(&opts.Filter, "filter", "f", "", "Text to filter by")
+	appCmd.PersistentFlags().StringVarP(&opts.Exclude, "exclude", "x", "", "Hide lines containing this text")
+	appCmd.PersistentFlags().IntVarP(&opts.Lines, "lines", "l", 50, "Number of lines per page")
+	appCmd.PersistentFlags().IntVarP(&opts.Page, "page", "p", 1, "Current page number")
+	appCmd.PersistentFlags().StringVarP(&opts.Ext, "ext", "e", "", "Accepted file extensions to search in folder")
+	appCmd.PersistentFlags().BoolVar(&opts.WithRegex, "with-regex", false, "Enable regex support")
+	appCmd.PersistentFlags().BoolVar(&opts.NoColor, "no-color", false, "Disable color output")
+	appCmd.PersistentFlags().BoolVar(&opts.LineNumbers, "line-numbers", false, "Show the line number before every line")
+	appCmd.PersistentFlags().BoolVar(&opts.TUI, "tui", false, "Browse the files in a full screen terminal navigator")
+	appCmd.PersistentFlags().BoolVar(&opts.Batch, "batch", false, "Print the pages and exit (enabled when the output is not a terminal)")
+	appCmd.PersistentFlags().StringVar(&opts.Pages, "pages", "", "Page range to print in batch mode or to export, e.g. 3, 3-7 or 3- (default all pages)")
+	appCmd.PersistentFlags().StringVar(&opts.Stats, "stats", "stderr", "Where the stats table goes in batch mode (stdout/stderr/none)")
+	appCmd.PersistentFlags().BoolVar(&opts.Grep, "grep", false, "Print matching lines as path:line:content and exit like grep does")
+	appCmd.PersistentFlags().BoolVarP(&opts.OnlyMatching, "only-matching", "o", false, "Print every match as path:line:column:match")
+	appCmd.PersistentFlags().BoolVarP(&opts.Count, "count", "c", false, "Print the number of matching lines for every file")
+	appCmd.PersistentFlags().BoolVar(&opts.FilesWithMatches, "files-with-matches", false, "Print only the paths of the files with matches")
+	appCmd.PersistentFlags().BoolVarP(&opts.FilesWithoutMatch, "files-without-match", "L", false, "Print only the paths of the files without matches")
+	appCmd.PersistentFlags().StringVar(&opts.Output, "output", "", "Print the matching lines as structured records (json/ndjson/csv)")
+	appCmd.PersistentFlags().BoolVar(&opts.Summary, "summary", false, "Print only the stats of every file in the structured output")
+	appCmd.PersistentFlags().StringVar(&opts.Export, "export", "", "Export the matching lines to a file and exit")
+	appCmd.PersistentFlags().IntVarP(&opts.Context, "context", "C", 0, "Number of lines to export or grep before and after every match")
+	appCmd.PersistentFlags().BoolVar(&opts.Compress, "compress", false, "Compress the exported file with gzip (enabled for .gz files)")
+	appCmd.PersistentFlags().BoolVar(&opts.Histogram, "histogram", false, "Show a histogram of the matches over time after the stats table")
+	appCmd.PersistentFlags().StringVar(&opts.Bucket, "bucket", "", "Time bucket size of the histogram, e.g. 30s, 5m, 1h or 1d (default automatic)")
+	appCmd.PersistentFlags().StringVar(&opts.Dedupe, "dedupe", "", "Collapse consecutive duplicate lines, identical (exact) or identical after masking numbers and timestamps (masked)")
+	appCmd.PersistentFlags().BoolVar(&opts.Merge, "merge", false, "Merge the lines of all files into a single stream ordered by timestamp")
+	// A bare --dedupe collapses identical lines
+	appCmd.PersistentFlags().Lookup("dedupe").NoOptDefVal = "exact"
+	appCmd.PersistentFlags().StringVar(&opts.Redact, "redact", "", "Hide emails, IPs, tokens, card numbers and AWS keys by replacing them with their kind (mask), a stable hash (hash) or nothing (drop)")
+	appCmd.PersistentFlags().StringArrayVar(&opts.RedactRules, "redact-rule", nil, "Regex of extra values to hide (only the group named value is hidden when there is one)")
+	// A bare --redact masks the values
+	appCmd.PersistentFlags().Lookup("redact").NoOptDefVal = "mask"
+	appCmd.PersistentFlags().IntVar(&opts.JSONDepth, "json-depth", 0, "Collapse JSON objects and arrays nested deeper than this (0 means never)")
+	appCmd.PersistentFlags().BoolVar(&opts.JSONCompact, "json-compact", false, "Print every JSON on a single line")
+	appCmd.PersistentFlags().StringVar(&opts.JSONExpand, "json-expand", "", "JSON fields always printed in full and indented (e.g. request,response.body)")
+	appCmd.PersistentFlags().StringVar(&opts.Theme, "theme", "dark", "Color theme (dark/light/high-contrast), colors can be changed in the config files")
+	appCmd.PersistentFlags().StringVar(&opts.Search, "search", "", "Name of a saved search to filter by (saved searches are regex)")
+	appCmd.PersistentFlags().StringVar(&profile, "profile", "", "Name of the config profile to use (from .logy.yaml or ~/.config/logy/config.yaml)")
 	// Run command
 	if err := appCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -0,0 +1,316 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Value shown for lines that do not have a group by field
+const missingField = "-"
+
+// Metric expressions like count, avg(latency) or p95(latency)
+var metricReg = regexp.MustCompile(`^(count|sum|avg|min|max|p(\d{1,2}(?:\.\d+)?))(?:\(([^()]+)\))?$`)
+
+// AggOptions holds the settings of an aggregation
+type AggOptions struct {
+	// Fields the lines are grouped by
+	GroupBy []string
+	// Metrics computed for every group, e.g. count, sum(bytes) or p95(latency_ms)
+	Metrics []string
+	// Format of the lines (auto/json/logfmt/nginx)
+	Format string
+	// Metric the groups are sorted by (descending)
+	Sort string
+	// Number of groups to print (0 means all)
+	Top int
+}
+
+// metric is a value computed for every group
+type metric struct {
+	// The expression typed by the user
+	name string
+	// count, sum, avg, min, max or p for percentiles
+	fn string
+	// Field the metric is computed on (empty for count)
+	field string
+	// Percentile for the p function
+	pct float64
+}
+
+// parseMetric parses a metric expression
+func parseMetric(s string) (metric, error) {
+	s = strings.TrimSpace(s)
+	m := metricReg.FindStringSubmatch(s)
+	if m == nil {
+		return metric{}, fmt.Errorf("Error! Invalid metric %q. Accepted metrics are count, sum(field), avg(field), min(field), max(field) and pNN(field)", s)
+	}
+	mt := metric{name: s, fn: m[1], field: strings.TrimSpace(m[3])}
+	if m[2] != "" {
+		mt.fn = "p"
+		mt.pct, _ = strconv.ParseFloat(m[2], 64)
+		if mt.pct <= 0 {
+			return metric{}, fmt.Errorf("Error! Invalid percentile in metric %q", s)
+		}
+	}
+	if mt.fn == "count" && mt.field != "" {
+		return metric{}, fmt.Errorf("Error! Metric count does not take a field")
+	}
+	if mt.fn != "count" && mt.field == "" {
+		return metric{}, fmt.Errorf("Error! Metric %s needs a field, e.g. %s(latency)", mt.fn, m[1])
+	}
+	return mt, nil
+}
+
+// compute returns the value of the metric for a group
+// The values of the metric field are sorted
+func (mt metric) compute(count int, values []float64) (float64, bool) {
+	if mt.fn == "count" {
+		return float64(count), true
+	}
+	if len(values) == 0 {
+		return 0, false
+	}
+	switch mt.fn {
+	case "sum", "avg":
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		if mt.fn == "avg" {
+			sum /= float64(len(values))
+		}
+		return sum, true
+	case "min":
+		return values[0], true
+	case "max":
+		return values[len(values)-1], true
+	}
+	// Nearest rank percentile
+	rank := int(math.Ceil(mt.pct / 100 * float64(len(values))))
+	if rank < 1 {
+		rank = 1
+	}
+	return values[rank-1], true
+}
+
+// aggGroup holds the lines having the same group by values
+type aggGroup struct {
+	keys  []string
+	count int
+	// Numeric values of every metric field
+	values map[string][]float64
+	// Computed metrics in the order they were asked for
+	results []float64
+	ok      []bool
+}
+
+// aggregator groups lines by fields and computes metrics for every group
+type aggregator struct {
+	groupBy []string
+	metrics []metric
+	// Fields used by the metrics, each one stored once
+	fields []string
+	format string
+	groups map[string]*aggGroup
+	// Groups in the order they were found
+	order []*aggGroup
+	// Number of lines without any field
+	skipped int
+}
+
+// newAggregator validates the options and returns an empty aggregator
+func newAggregator(opts AggOptions) (*aggregator, error) {
+	if opts.Format == "" {
+		opts.Format = "auto"
+	}
+	if !stringInSlice(opts.Format, fieldFormats) {
+		return nil, fmt.Errorf("Error! Accepted formats are: %s", strings.Join(fieldFormats, ", "))
+	}
+	if opts.Top < 0 {
+		return nil, errors.New("Error! Option flag -top cannot be negative")
+	}
+	a := &aggregator{format: opts.Format, groups: make(map[string]*aggGroup)}
+	for _, g := range opts.GroupBy {
+		for _, field := range strings.Split(g, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				a.groupBy = append(a.groupBy, field)
+			}
+		}
+	}
+	if len(opts.Metrics) == 0 {
+		opts.Metrics = []string{"count"}
+	}
+	for _, s := range opts.Metrics {
+		mt, err := parseMetric(s)
+		if err != nil {
+			return nil, err
+		}
+		a.metrics = append(a.metrics, mt)
+		if mt.field != "" && !stringInSlice(mt.field, a.fields) {
+			a.fields = append(a.fields, mt.field)
+		}
+	}
+	return a, nil
+}
+
+// add puts a line in its group
+func (a *aggregator) add(line string) {
+	fields := extractFields(line, a.format)
+	if fields == nil {
+		a.skipped++
+		return
+	}
+	keys := make([]string, len(a.groupBy))
+	for i, name := range a.groupBy {
+		keys[i] = missingField
+		if v, ok := fieldValue(fields, name); ok && v != nil {
+			keys[i] = fieldString(v)
+		}
+	}
+	// The unit separator never shows up in log fields
+	id := strings.Join(keys, "\x1f")
+	g, ok := a.groups[id]
+	if !ok {
+		g = &aggGroup{keys: keys, values: make(map[string][]float64)}
+		a.groups[id] = g
+		a.order = append(a.order, g)
+	}
+	g.count++
+	// Values that are not numbers are ignored by the metrics
+	for _, name := range a.fields {
+		v, ok := fieldValue(fields, name)
+		if !ok {
+			continue
+		}
+		if n, ok := toNumber(v); ok {
+			g.values[name] = append(g.values[name], n)
+		}
+	}
+}
+
+// results computes the metrics and sorts the groups by the given metric
+func (a *aggregator) results(sortBy string) ([]*aggGroup, error) {
+	// Groups are sorted by the first metric unless told otherwise
+	sortIdx := 0
+	if sortBy != "" {
+		sortIdx = -1
+		for i, mt := range a.metrics {
+			if mt.name == sortBy {
+				sortIdx = i
+			}
+		}
+		if sortIdx < 0 {
+			return nil, fmt.Errorf("Error! Cannot sort by %q because it is not one of the metrics", sortBy)
+		}
+	}
+	for _, g := range a.order {
+		for _, values := range g.values {
+			sort.Float64s(values)
+		}
+		g.results = make([]float64, len(a.metrics))
+		g.ok = make([]bool, len(a.metrics))
+		for i, mt := range a.metrics {
+			g.results[i], g.ok[i] = mt.compute(g.count, g.values[mt.field])
+		}
+	}
+	groups := make([]*aggGroup, len(a.order))
+	copy(groups, a.order)
+	sort.SliceStable(groups, func(i, j int) bool {
+		gi, gj := groups[i], groups[j]
+		// Groups without a value go last
+		if gi.ok[sortIdx] != gj.ok[sortIdx] {
+			return gi.ok[sortIdx]
+		}
+		return gi.results[sortIdx] > gj.results[sortIdx]
+	})
+	return groups, nil
+}
+
+// toNumber converts a field value to a number
+// Strings are accepted as long as they contain a number, e.g. "0.25"
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	case bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// formatNumber prints a number without useless decimals
+// Decimals are rounded to 3 digits, e.g. 0.123 or 876.3
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1000)/1000, 'f', -1, 64)
+}
+
+// Aggregate groups the matching lines by fields and prints the metrics of every group
+func (p *Parser) Aggregate(opts AggOptions) {
+	a, err := newAggregator(opts)
+	if err != nil {
+		exitWithError(err.Error())
+	}
+	fs := visibleStats(p.collectStats())
+	for _, stat := range fs {
+		err := p.eachRecord(stat, func(r record) bool {
+			a.add(r.Text)
+			return true
+		})
+		if err != nil {
+			fatal(err)
+		}
+	}
+	groups, err := a.results(opts.Sort)
+	if err != nil {
+		exitWithError(err.Error())
+	}
+	if len(groups) == 0 {
+		fmt.Printf("%s\n", info("Sorry. Nothing to show here!"))
+		return
+	}
+	if opts.Top > 0 && len(groups) > opts.Top {
+		groups = groups[:opts.Top]
+	}
+	renderAggregation(os.Stdout, a, groups)
+}
+
+// renderAggregation prints the groups and their metrics as a table
+func renderAggregation(w io.Writer, a *aggregator, groups []*aggGroup) {
+	table := newTable(w)
+	// Keep the field names as they are typed
+	table.SetAutoFormatHeaders(false)
+	header := append([]string{}, a.groupBy...)
+	for _, mt := range a.metrics {
+		header = append(header, mt.name)
+	}
+	setTableHeader(table, header)
+	for _, g := range groups {
+		row := append([]string{}, g.keys...)
+		for i := range a.metrics {
+			value := missingField
+			if g.ok[i] {
+				value = formatNumber(g.results[i])
+			}
+			row = append(row, value)
+		}
+		table.Append(row)
+	}
+	fmt.Fprintln(w, info(fmt.Sprintf("%d groups found", len(a.order))))
+	table.Render()
+	if a.skipped > 0 {
+		fmt.Fprintln(w, info(fmt.Sprintf("%d lines without fields were skipped", a.skipped)))
+	}
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestParseMetric tests if metric expressions are parsed
+func TestParseMetric(t *testing.T) {
+	tests := []struct {
+		in   string
+		want metric
+		err  bool
+	}{
+		{"count", metric{name: "count", fn: "count"}, false},
+		{"avg(latency)", metric{name: "avg(latency)", fn: "avg", field: "latency"}, false},
+		{"p95(http.latency)", metric{name: "p95(http.latency)", fn: "p", field: "http.latency", pct: 95}, false},
+		{"p99.9(latency)", metric{name: "p99.9(latency)", fn: "p", field: "latency", pct: 99.9}, false},
+		{"count(latency)", metric{}, true},
+		{"sum", metric{}, true},
+		{"p0(latency)", metric{}, true},
+		{"median(latency)", metric{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseMetric(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseMetric(%q) = %+v, %v, want %+v, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+// TestAggregator tests if lines are grouped and metrics are computed
+func TestAggregator(t *testing.T) {
+	a, err := newAggregator(AggOptions{
+		GroupBy: []string{"path"},
+		Metrics: []string{"count", "avg(ms)", "p50(ms)", "max(ms)"},
+		Sort:    "max(ms)",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 10; i++ {
+		a.add(fmt.Sprintf(`{"path":"/fast","ms":%d}`, i))
+	}
+	a.add("path=/slow ms=500")
+	a.add("path=/slow ms=oops")
+	a.add("ms=7")
+	a.add("no fields here")
+
+	groups, err := a.results("max(ms)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.skipped != 1 {
+		t.Errorf("got %d skipped lines, want 1", a.skipped)
+	}
+	var got []string
+	for _, g := range groups {
+		row := g.keys[0]
+		for i := range a.metrics {
+			value := missingField
+			if g.ok[i] {
+				value = formatNumber(g.results[i])
+			}
+			row += " " + value
+		}
+		got = append(got, row)
+	}
+	want := []string{
+		"/slow 2 500 500 500",
+		"/fast 10 5.5 5 10",
+		"- 1 7 7 7",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := a.results("min(ms)"); err == nil {
+		t.Errorf("sorting by a metric that was not asked for must fail")
+	}
+}
@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parsePageRange parses a page range like 3, 3-7 or 3-
+// An empty range means all pages. A zero upper bound means the last page
+func parsePageRange(s string) (int, int, error) {
+	if s == "" {
+		return 1, 0, nil
+	}
+	bounds := strings.SplitN(s, "-", 2)
+	from, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil || from <= 0 {
+		return 0, 0, errors.New("Error! Option flag -pages must be a page number or a range like 3-7")
+	}
+	// A single page
+	if len(bounds) == 1 {
+		return from, from, nil
+	}
+	// An open range goes up to the last page
+	if strings.TrimSpace(bounds[1]) == "" {
+		return from, 0, nil
+	}
+	to, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if err != nil || to < from {
+		return 0, 0, errors.New("Error! Option flag -pages must be a page number or a range like 3-7")
+	}
+	return from, to, nil
+}
+
+// runBatch prints the requested pages of all files and returns
+// No input is expected from the user so it is safe to use in scripts
+func (p *Parser) runBatch(fs []stats) {
+	if err := p.writeBatch(os.Stdout, os.Stderr, fs); err != nil {
+		exitWithError(err.Error())
+	}
+}
+
+// writeBatch writes the requested pages of all files to stdout
+// The stats table goes to stdout or stderr depending on the stats option
+func (p *Parser) writeBatch(stdout, stderr io.Writer, fs []stats) error {
+	// A single file must have the requested page
+	if len(fs) == 1 && p.from > len(fs[0].offsets) {
+		return fmt.Errorf("Error! Page number cannot be greater than %d", len(fs[0].offsets))
+	}
+	// Send the stats table where the user wants it
+	var w io.Writer
+	switch p.statsOut {
+	case "stdout":
+		w = stdout
+	case "stderr":
+		w = stderr
+	}
+	if w != nil {
+		renderStats(w, fs, 1)
+		fmt.Fprintln(w)
+		if p.histogram {
+			renderHistogram(w, p.matchHistogram(fs))
+			fmt.Fprintln(w)
+		}
+	}
+	// The colors of the filter terms are told once above the lines
+	io.WriteString(stdout, p.legend())
+	for _, stat := range fs {
+		numPages := len(stat.offsets)
+		// Files that do not have the requested pages are skipped
+		if p.from > numPages {
+			continue
+		}
+		to := p.to
+		if to == 0 || to > numPages {
+			to = numPages
+		}
+		// Tell where the output comes from when there are more files
+		if len(fs) > 1 {
+			fmt.Fprintln(stdout, info(fmt.Sprintf("==> %s <==", stat.path)))
+		}
+		for page := p.from; page <= to; page++ {
+			text, err := p.getFilePage(stat.path, stat.offsets[page-1], stat.firstLine(page))
+			if err != nil {
+				return err
+			}
+			io.WriteString(stdout, text)
+		}
+	}
+	return nil
+}
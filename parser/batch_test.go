@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestBatchPages tests if only the pages of the range are printed
+func TestBatchPages(t *testing.T) {
+	path, cleanup := writeTestLog(t, 10, nil)
+	defer cleanup()
+
+	tests := []struct {
+		pages string
+		first string
+		last  string
+		lines int
+	}{
+		{"", "INFO line 1", "INFO line 10", 10},
+		{"2", "INFO line 4", "INFO line 6", 3},
+		{"2-3", "INFO line 4", "INFO line 9", 6},
+		{"3-", "INFO line 7", "INFO line 10", 4},
+		// The upper bound stops at the last page
+		{"4-9", "INFO line 10", "INFO line 10", 1},
+	}
+	for _, tt := range tests {
+		p := New(path, Options{Text: "plain", Lines: 3, Page: 1, NoColor: true, Pages: tt.pages, Stats: "none"})
+		var stdout, stderr bytes.Buffer
+		if err := p.writeBatch(&stdout, &stderr, p.collectStats()); err != nil {
+			t.Fatalf("%q: %v", tt.pages, err)
+		}
+		lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+		if len(lines) != tt.lines || lines[0] != tt.first || lines[len(lines)-1] != tt.last {
+			t.Errorf("%q: got %q", tt.pages, lines)
+		}
+	}
+}
+
+// TestBatchStats tests if the stats table goes to the requested output
+func TestBatchStats(t *testing.T) {
+	path, cleanup := writeTestLog(t, 10, nil)
+	defer cleanup()
+
+	tests := []struct {
+		stats    string
+		inStdout bool
+		inStderr bool
+	}{
+		{"stdout", true, false},
+		{"stderr", false, true},
+		{"none", false, false},
+	}
+	for _, tt := range tests {
+		p := New(path, Options{Text: "plain", Lines: 3, Page: 1, NoColor: true, Stats: tt.stats})
+		var stdout, stderr bytes.Buffer
+		if err := p.writeBatch(&stdout, &stderr, p.collectStats()); err != nil {
+			t.Fatalf("%s: %v", tt.stats, err)
+		}
+		if got := strings.Contains(stdout.String(), "Current File ID"); got != tt.inStdout {
+			t.Errorf("%s: stats in stdout is %v, want %v", tt.stats, got, tt.inStdout)
+		}
+		if got := strings.Contains(stderr.String(), "Current File ID"); got != tt.inStderr {
+			t.Errorf("%s: stats in stderr is %v, want %v", tt.stats, got, tt.inStderr)
+		}
+		// The pages always go to stdout
+		if !strings.Contains(stdout.String(), "INFO line 10") {
+			t.Errorf("%s: pages missing from stdout", tt.stats)
+		}
+	}
+}
+
+// TestBatchPageOutOfRange tests if a range starting after the last page is an error
+func TestBatchPageOutOfRange(t *testing.T) {
+	path, cleanup := writeTestLog(t, 10, nil)
+	defer cleanup()
+
+	p := New(path, Options{Text: "plain", Lines: 3, Page: 1, NoColor: true, Pages: "5-6", Stats: "stdout"})
+	var stdout, stderr bytes.Buffer
+	err := p.writeBatch(&stdout, &stderr, p.collectStats())
+	if err == nil || err.Error() != "Error! Page number cannot be greater than 4" {
+		t.Errorf("got error %v", err)
+	}
+	if stdout.Len() > 0 || stderr.Len() > 0 {
+		t.Errorf("got output %q %q", stdout.String(), stderr.String())
+	}
+}
+
+// TestBatchLegend tests if the colors of the filter terms are told once and not on every page
+func TestBatchLegend(t *testing.T) {
+	path, cleanup := writeTestLog(t, 10, nil)
+	defer cleanup()
+	defer forceBasicColors()()
+
+	p := New(path, Options{Text: "plain", Lines: 3, Page: 1, Filter: "INFO|line", WithRegex: true, Stats: "none"})
+	fs := p.collectStats()
+	var stdout, stderr bytes.Buffer
+	if err := p.writeBatch(&stdout, &stderr, fs); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(stdout.String(), "Terms:"); n != 1 || !strings.HasPrefix(stripANSI(stdout.String()), "Terms: INFO  line\n") {
+		t.Errorf("got the legend %d times:\n%s", n, stdout.String())
+	}
+	// The pages only hold the lines of the file
+	page, err := p.getFilePage(path, fs[0].offsets[1], fs[0].firstLine(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stripANSI(page); got != "INFO line 4\nINFO line 5\nINFO line 6\n" {
+		t.Errorf("got page %q", got)
+	}
+}
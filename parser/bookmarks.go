@@ -0,0 +1,323 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Accepted formats for the bookmarks timeline
+var timelineFormats = []string{
+	"md",
+	"json",
+}
+
+// bookmark is a line marked by the user during an interactive session
+type bookmark struct {
+	Path string `json:"file"`
+	Line int    `json:"line"`
+	// Timestamp found in the bookmarked line (if any)
+	Time string `json:"timestamp,omitempty"`
+	Note string `json:"note"`
+	Text string `json:"text"`
+}
+
+// bookmarksPath returns the path of the file where the bookmarks of a log file are stored
+// It is kept next to the log file so everyone opening the log can see them
+func bookmarksPath(path string) string {
+	dir, name := filepath.Split(path)
+	return filepath.Join(dir, "."+name+".bookmarks")
+}
+
+// userBookmarksPath returns the file used when the bookmarks cannot be kept next to the log,
+// e.g. for logs in /var/log. It lives in the user folder and is named after the log path
+// It is empty when the user folder is unknown
+func userBookmarksPath(path string) string {
+	dir := userDir()
+	if dir == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(dir, "bookmarks", hex.EncodeToString(sum[:8])+".json")
+}
+
+// readBookmarks reads the bookmarks stored for a log file
+// The user file wins because it only exists when the file next to the log could not be written
+func readBookmarks(path string) ([]bookmark, error) {
+	for _, file := range []string{userBookmarksPath(path), bookmarksPath(path)} {
+		if file == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read bookmarks for %s. Error: %v", path, err)
+		}
+		var marks []bookmark
+		if err := json.Unmarshal(data, &marks); err != nil {
+			return nil, fmt.Errorf("Cannot read bookmarks for %s. Error: %v", path, err)
+		}
+		for i := range marks {
+			// The log may have been opened through another path
+			marks[i].Path = path
+		}
+		return marks, nil
+	}
+	// No bookmarks for this file
+	return nil, nil
+}
+
+// writeBookmarks stores the bookmarks of a log file next to it
+// When its folder is read only they go to the user folder instead
+func writeBookmarks(path string, marks []bookmark) error {
+	data, err := json.MarshalIndent(marks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error! Cannot save bookmarks: %v", err)
+	}
+	// Do not leave empty bookmark files behind
+	var sideErr error
+	if len(marks) == 0 {
+		if err := os.Remove(bookmarksPath(path)); err != nil && !os.IsNotExist(err) {
+			sideErr = err
+		}
+	} else {
+		sideErr = ioutil.WriteFile(bookmarksPath(path), data, 0644)
+	}
+	user := userBookmarksPath(path)
+	if sideErr == nil {
+		// The file next to the log is up to date again
+		if user != "" {
+			if err := os.Remove(user); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("Error! Cannot remove bookmarks: %v", err)
+			}
+		}
+		return nil
+	}
+	if user == "" {
+		return fmt.Errorf("Error! Cannot save bookmarks next to %s: %v", path, sideErr)
+	}
+	// An empty list hides the bookmarks that could not be removed
+	err = os.MkdirAll(filepath.Dir(user), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(user, data, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("Error! Cannot save bookmarks next to %s (%v) or in %s (%v)", path, sideErr, user, err)
+	}
+	return nil
+}
+
+// loadBookmarks reads the bookmarks stored for all files
+func (n *navigator) loadBookmarks() {
+	n.marks = nil
+	for _, stat := range n.all {
+		marks, err := readBookmarks(stat.path)
+		if err != nil {
+			fmt.Printf("%s\n", fail(err.Error()))
+			continue
+		}
+		n.marks = append(n.marks, marks...)
+	}
+}
+
+// saveBookmarks stores the bookmarks of the given file
+func (n *navigator) saveBookmarks(path string) error {
+	var marks []bookmark
+	for _, m := range n.marks {
+		if m.Path == path {
+			marks = append(marks, m)
+		}
+	}
+	return writeBookmarks(path, marks)
+}
+
+// addBookmark marks the n-th displayed line of the current page
+func (n *navigator) addBookmark(line int, note string) error {
+	stat := n.current()
+	start := stat.firstLine(n.page)
+	// Count only the lines that are displayed
+	lines, err := n.p.readPage(stat.path, stat.offsets[n.page-1], start)
+	if err != nil {
+		return err
+	}
+	shown := 0
+	for _, l := range lines {
+		text := l.text
+		if n.p.excluded([]byte(text)) {
+			continue
+		}
+		shown++
+		if shown != line {
+			continue
+		}
+		m := bookmark{Path: stat.path, Line: l.number, Note: note, Text: n.p.redact(text)}
+		if t, ok := parseTimestamp(text); ok {
+			m.Time = t.Format(time.RFC3339Nano)
+		}
+		// Replace any previous bookmark for the same line
+		n.removeLine(m.Path, m.Line)
+		n.marks = append(n.marks, m)
+		sort.SliceStable(n.marks, func(i, j int) bool {
+			if n.marks[i].Path != n.marks[j].Path {
+				return n.fileIndex(n.marks[i].Path) < n.fileIndex(n.marks[j].Path)
+			}
+			return n.marks[i].Line < n.marks[j].Line
+		})
+		if err := n.saveBookmarks(m.Path); err != nil {
+			return err
+		}
+		fmt.Printf("\n%s\n\n", info(fmt.Sprintf("Line %d of %s was bookmarked", m.Line, m.Path)))
+		return nil
+	}
+	return fmt.Errorf("Error! Line number must be between 1 and %d", shown)
+}
+
+// removeBookmark deletes a bookmark by its number in the list
+func (n *navigator) removeBookmark(num int) error {
+	if num < 1 || num > len(n.marks) {
+		return n.bookmarkRangeError()
+	}
+	m := n.marks[num-1]
+	n.removeLine(m.Path, m.Line)
+	if err := n.saveBookmarks(m.Path); err != nil {
+		return err
+	}
+	fmt.Printf("\n%s\n\n", info(fmt.Sprintf("Bookmark for line %d of %s was removed", m.Line, m.Path)))
+	return nil
+}
+
+// removeLine deletes the bookmark of a given line
+func (n *navigator) removeLine(path string, line int) {
+	marks := n.marks[:0]
+	for _, m := range n.marks {
+		if m.Path != path || m.Line != line {
+			marks = append(marks, m)
+		}
+	}
+	n.marks = marks
+}
+
+// fileIndex returns the position of a file among all files
+func (n *navigator) fileIndex(path string) int {
+	for i, stat := range n.all {
+		if stat.path == path {
+			return i
+		}
+	}
+	return len(n.all)
+}
+
+// listBookmarks prints all bookmarks
+func (n *navigator) listBookmarks() error {
+	if len(n.marks) == 0 {
+		return errors.New("Error! There are no bookmarks yet")
+	}
+	fmt.Printf("\n%s\n", info("Bookmarks:"))
+	for i, m := range n.marks {
+		fmt.Printf("%3d. %s:%d %s %s\n", i+1, m.Path, m.Line, m.Time, alert(m.Note))
+		fmt.Printf("     %s\n", m.Text)
+	}
+	fmt.Println()
+	return nil
+}
+
+// gotoBookmark navigates to the page containing a bookmark
+func (n *navigator) gotoBookmark(num int) error {
+	if num < 1 || num > len(n.marks) {
+		return n.bookmarkRangeError()
+	}
+	m := n.marks[num-1]
+	for id, stat := range n.fs {
+		if stat.path != m.Path {
+			continue
+		}
+		if m.Line > stat.lines {
+			return errors.New("Error! The bookmarked line no longer exists")
+		}
+		if page, ok := stat.findLine(m.Line); ok {
+			return n.run(command{action: actionGoto, id: id + 1, page: page})
+		}
+		break
+	}
+	return errors.New("Error! The bookmarked line is hidden by the current filter")
+}
+
+// bookmarkRangeError tells the user which bookmark numbers are valid
+func (n *navigator) bookmarkRangeError() error {
+	if len(n.marks) == 0 {
+		return errors.New("Error! There are no bookmarks yet")
+	}
+	return fmt.Errorf("Error! Bookmark number must be between 1 and %d", len(n.marks))
+}
+
+// exportTimeline writes all bookmarks as an incident timeline
+// The timeline goes to the given file or to the console when no file is given
+func (n *navigator) exportTimeline(format, path string) error {
+	if len(n.marks) == 0 {
+		return errors.New("Error! There are no bookmarks yet")
+	}
+	if !stringInSlice(format, timelineFormats) {
+		return fmt.Errorf("Error! Accepted timeline formats are: %s", strings.Join(timelineFormats, ", "))
+	}
+	// Bookmarks with a timestamp are ordered chronologically
+	// The others follow them in the order they have in the files
+	var marks, untimed []bookmark
+	for _, m := range n.marks {
+		if m.Time == "" {
+			untimed = append(untimed, m)
+		} else {
+			marks = append(marks, m)
+		}
+	}
+	sort.SliceStable(marks, func(i, j int) bool {
+		ti, _ := time.Parse(time.RFC3339Nano, marks[i].Time)
+		tj, _ := time.Parse(time.RFC3339Nano, marks[j].Time)
+		return ti.Before(tj)
+	})
+	marks = append(marks, untimed...)
+
+	var w io.Writer = os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("Error! Cannot create timeline file: %v", err)
+		}
+		defer f.Close()
+		w = f
+	} else {
+		fmt.Println()
+	}
+	if format == "json" {
+		data, err := json.MarshalIndent(marks, "", "  ")
+		if err != nil {
+			return fmt.Errorf("Error! Cannot encode timeline: %v", err)
+		}
+		fmt.Fprintf(w, "%s\n", data)
+	} else {
+		fmt.Fprintln(w, "| Timestamp | File | Line | Note |")
+		fmt.Fprintln(w, "| --- | --- | --- | --- |")
+		for _, m := range marks {
+			fmt.Fprintf(w, "| %s | %s | %d | %s |\n", m.Time, m.Path, m.Line, strings.Replace(m.Note, "|", "\\|", -1))
+		}
+	}
+	if path != "" {
+		fmt.Printf("\n%s\n\n", info(fmt.Sprintf("Timeline was written to %s", path)))
+	} else {
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// withUserDir points the user folder to a temporary folder during a test
+func withUserDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "logy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, ok := os.LookupEnv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	return dir, func() {
+		if ok {
+			os.Setenv("XDG_CONFIG_HOME", old)
+		} else {
+			os.Unsetenv("XDG_CONFIG_HOME")
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+// TestBookmarksRoundTrip tests saving, loading and deleting bookmarks
+func TestBookmarksRoundTrip(t *testing.T) {
+	dir, cleanup := withUserDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "app.log")
+	marks := []bookmark{
+		{Path: path, Line: 3, Time: "2020-01-01T10:00:00Z", Note: "first", Text: "line 3"},
+		{Path: path, Line: 9, Note: "second", Text: "line 9"},
+	}
+	if err := writeBookmarks(path, marks); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".app.log.bookmarks")); err != nil {
+		t.Errorf("bookmarks are not next to the log: %v", err)
+	}
+	// The log opened through another path still gets its bookmarks
+	other := filepath.Join(dir, ".", "app.log")
+	got, err := readBookmarks(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range marks {
+		marks[i].Path = other
+	}
+	if !reflect.DeepEqual(got, marks) {
+		t.Errorf("readBookmarks() = %v, want %v", got, marks)
+	}
+	// Deleting the last bookmark removes the file
+	if err := writeBookmarks(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".app.log.bookmarks")); !os.IsNotExist(err) {
+		t.Errorf("empty bookmarks file was left behind: %v", err)
+	}
+	if got, err := readBookmarks(path); err != nil || got != nil {
+		t.Errorf("readBookmarks() after deletion = %v, %v, want nothing", got, err)
+	}
+}
+
+// TestBookmarksFallback tests saving bookmarks when the file next to the log cannot be written
+func TestBookmarksFallback(t *testing.T) {
+	dir, cleanup := withUserDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "app.log")
+	// A folder in place of the bookmarks file cannot be written, even by root
+	if err := os.Mkdir(bookmarksPath(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	marks := []bookmark{{Path: path, Line: 1, Note: "n", Text: "t"}}
+	if err := writeBookmarks(path, marks); err != nil {
+		t.Fatal(err)
+	}
+	user := userBookmarksPath(path)
+	if _, err := os.Stat(user); err != nil {
+		t.Fatalf("bookmarks were not saved in the user folder: %v", err)
+	}
+	if got, err := readBookmarks(path); err != nil || !reflect.DeepEqual(got, marks) {
+		t.Errorf("readBookmarks() = %v, %v, want %v", got, err, marks)
+	}
+	// Once the log folder is writable again the user file is removed
+	os.Remove(bookmarksPath(path))
+	if err := writeBookmarks(path, marks); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(user); !os.IsNotExist(err) {
+		t.Errorf("user bookmarks file was left behind: %v", err)
+	}
+	// When the user folder cannot be written either the error tells where saving failed
+	os.Remove(bookmarksPath(path))
+	os.Mkdir(bookmarksPath(path), 0755)
+	os.RemoveAll(filepath.Join(dir, "config"))
+	ioutil.WriteFile(filepath.Join(dir, "config"), nil, 0644)
+	if err := writeBookmarks(path, marks); err == nil {
+		t.Error("writeBookmarks() expected an error when no file can be written")
+	}
+}
+
+// TestTimelineOrder tests that the timeline is chronological with untimed bookmarks last
+func TestTimelineOrder(t *testing.T) {
+	dir, cleanup := withUserDir(t)
+	defer cleanup()
+	n := &navigator{marks: []bookmark{
+		{Path: "a.log", Line: 1, Note: "untimed"},
+		{Path: "a.log", Line: 5, Time: "2020-01-01T10:00:02Z", Note: "third"},
+		{Path: "b.log", Line: 2, Time: "2020-01-01T10:00:00Z", Note: "first"},
+		{Path: "b.log", Line: 7, Note: "untimed too"},
+		{Path: "a.log", Line: 9, Time: "2020-01-01T10:00:01.5Z", Note: "second"},
+	}}
+	out := filepath.Join(dir, "timeline.json")
+	if err := n.exportTimeline("json", out); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var marks []bookmark
+	if err := json.Unmarshal(data, &marks); err != nil {
+		t.Fatal(err)
+	}
+	var notes []string
+	for _, m := range marks {
+		notes = append(notes, m.Note)
+	}
+	want := []string{"first", "second", "third", "untimed", "untimed too"}
+	if !reflect.DeepEqual(notes, want) {
+		t.Errorf("timeline order = %q, want %q", notes, want)
+	}
+	if err := n.exportTimeline("xml", out); err == nil {
+		t.Error("exportTimeline() expected an error for an unknown format")
+	}
+}
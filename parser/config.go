@@ -0,0 +1,249 @@
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// Name of the project config file, looked up in the working directory
+const projectConfig = ".logy.yaml"
+
+// Flag selecting a profile. It cannot be set by the config files
+const profileFlag = "profile"
+
+// config is the content of a config file
+// Keys are the long names of the flags, e.g. ext, lines or text
+type config struct {
+	// Values used by every invocation
+	Defaults map[string]interface{} `yaml:"defaults"`
+	// Named groups of values picked with --profile
+	Profiles map[string]map[string]interface{} `yaml:"profiles"`
+	// Colors replacing the ones of the theme, e.g. match or level.error
+	Colors map[string]interface{} `yaml:"colors"`
+}
+
+// userDir returns the folder of the user files: $XDG_CONFIG_HOME/logy or ~/.config/logy
+// It is empty when the home folder is unknown
+func userDir() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "logy")
+}
+
+// configPaths returns the config files from the lowest to the highest priority
+func configPaths() []string {
+	var paths []string
+	if dir := userDir(); dir != "" {
+		paths = append(paths, filepath.Join(dir, "config.yaml"))
+	}
+	return append(paths, projectConfig)
+}
+
+// readConfig reads a config file
+// Missing files are not an error, they are just empty
+func readConfig(path string) (*config, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error! Cannot read config file %s: %v", path, err)
+	}
+	var c config
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, fmt.Errorf("Error! Invalid config file %s: %v", path, err)
+	}
+	return &c, nil
+}
+
+// loadConfig merges the values of all config files and of the given profile
+// Project values override user values and profile values override defaults
+// Every value is returned as a list of strings ready to be given to a flag
+func loadConfig(paths []string, profile string) (map[string][]string, error) {
+	var configs []*config
+	for _, path := range paths {
+		c, err := readConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			configs = append(configs, c)
+		}
+	}
+	settings := make(map[string][]string)
+	merge := func(values map[string]interface{}) error {
+		for key, value := range values {
+			list, err := configValues(key, value)
+			if err != nil {
+				return err
+			}
+			settings[key] = list
+		}
+		return nil
+	}
+	for _, c := range configs {
+		if err := merge(c.Defaults); err != nil {
+			return nil, err
+		}
+	}
+	if profile == "" {
+		return settings, nil
+	}
+	var found bool
+	for _, c := range configs {
+		values, ok := c.Profiles[profile]
+		if !ok {
+			continue
+		}
+		found = true
+		if err := merge(values); err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("Error! Profile %q is not defined in %s", profile, strings.Join(paths, " or "))
+	}
+	return settings, nil
+}
+
+// loadColors merges the colors of all config files
+// Nested keys are joined by dots and lists by commas, e.g. level: {error: red} gives level.error
+func loadColors(paths []string) (map[string]string, error) {
+	colors := make(map[string]string)
+	var add func(prefix string, values map[string]interface{}) error
+	add = func(prefix string, values map[string]interface{}) error {
+		for key, value := range values {
+			key = prefix + key
+			if nested, ok := value.(map[interface{}]interface{}); ok {
+				m := make(map[string]interface{}, len(nested))
+				for k, v := range nested {
+					m[fmt.Sprint(k)] = v
+				}
+				if err := add(key+".", m); err != nil {
+					return err
+				}
+				continue
+			}
+			list, err := configValues("colors."+key, value)
+			if err != nil {
+				return err
+			}
+			colors[key] = strings.Join(list, ",")
+		}
+		return nil
+	}
+	for _, path := range paths {
+		c, err := readConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			continue
+		}
+		if err := add("", c.Colors); err != nil {
+			return nil, err
+		}
+	}
+	return colors, nil
+}
+
+// configValues converts a config value to the strings given to a flag
+// Lists give one string per item, e.g. for --metric or --redact-rule
+func configValues(key string, value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, fmt.Errorf("Error! Option %s in config has no value", key)
+	case []interface{}:
+		list := make([]string, len(v))
+		for i, item := range v {
+			list[i] = fieldString(item)
+		}
+		return list, nil
+	case map[interface{}]interface{}:
+		return nil, fmt.Errorf("Error! Option %s in config must be a value or a list", key)
+	}
+	return []string{fieldString(value)}, nil
+}
+
+// ApplyConfig sets the flags of the running command and the colors from the config files and the given profile
+// Flags given on the command line always win, then the profile,
+// then the project config (.logy.yaml) and last the user config (~/.config/logy/config.yaml)
+// Keys of the other commands are skipped, e.g. metric is only used by agg
+func ApplyConfig(cmd *cobra.Command, profile string) {
+	settings, err := loadConfig(configPaths(), profile)
+	if err != nil {
+		exitWithError(err.Error())
+	}
+	// Colors are checked when the theme is picked
+	if colorOverrides, err = loadColors(configPaths()); err != nil {
+		exitWithError(err.Error())
+	}
+	// The flags of the running command include the inherited global flags
+	flags := cmd.Flags()
+	others := commandFlags(cmd.Root())
+	// Sorted keys keep the errors the same from run to run
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if flags.Lookup(key) == nil && others[key] && key != profileFlag {
+			continue
+		}
+		if err := setFlag(flags, key, settings[key]); err != nil {
+			exitWithError(err.Error())
+		}
+	}
+}
+
+// commandFlags returns the names of the flags of a command and of all its subcommands
+func commandFlags(cmd *cobra.Command) map[string]bool {
+	names := make(map[string]bool)
+	add := func(f *pflag.Flag) {
+		names[f.Name] = true
+	}
+	cmd.Flags().VisitAll(add)
+	cmd.PersistentFlags().VisitAll(add)
+	for _, sub := range cmd.Commands() {
+		for name := range commandFlags(sub) {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// setFlag gives the config values to a flag unless it was set on the command line
+func setFlag(flags *pflag.FlagSet, key string, values []string) error {
+	f := flags.Lookup(key)
+	if f == nil || key == profileFlag {
+		return fmt.Errorf("Error! Unknown option %s in config", key)
+	}
+	if f.Changed {
+		return nil
+	}
+	// Flags taking a single value get the items of a list joined by commas, e.g. ext: [log, txt]
+	if t := f.Value.Type(); t != "stringArray" && t != "stringSlice" {
+		values = []string{strings.Join(values, ",")}
+	}
+	for _, value := range values {
+		if err := flags.Set(key, value); err != nil {
+			return fmt.Errorf("Error! Invalid value %q for option %s in config: %v", value, key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// writeConfigs writes config files in the given folder and returns their paths
+func writeConfigs(t *testing.T, dir string, contents ...string) []string {
+	var paths []string
+	for i, content := range contents {
+		path := filepath.Join(dir, string(rune('a'+i))+".yaml")
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	// Missing files are ignored
+	return append(paths, filepath.Join(dir, "missing.yaml"))
+}
+
+// TestLoadConfig tests the precedence of the config files and the profiles
+func TestLoadConfig(t *testing.T) {
+	user := `
+defaults:
+  lines: 100
+  ext: [log, txt]
+  text: json
+profiles:
+  nginx-errors:
+    filter: ' 5\d\d '
+    with-regex: true
+    exclude: healthcheck
+`
+	project := `
+defaults:
+  lines: 20
+profiles:
+  nginx-errors:
+    exclude: /metrics
+  metrics:
+    metric: [count, p95(latency)]
+`
+	dir, err := ioutil.TempDir("", "logy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	paths := writeConfigs(t, dir, user, project)
+	tests := []struct {
+		profile string
+		want    map[string][]string
+	}{
+		{"", map[string][]string{"lines": {"20"}, "ext": {"log", "txt"}, "text": {"json"}}},
+		{"nginx-errors", map[string][]string{
+			"lines":      {"20"},
+			"ext":        {"log", "txt"},
+			"text":       {"json"},
+			"filter":     {` 5\d\d `},
+			"with-regex": {"true"},
+			"exclude":    {"/metrics"},
+		}},
+		{"metrics", map[string][]string{"lines": {"20"}, "ext": {"log", "txt"}, "text": {"json"}, "metric": {"count", "p95(latency)"}}},
+	}
+	for _, tt := range tests {
+		got, err := loadConfig(paths, tt.profile)
+		if err != nil {
+			t.Fatalf("loadConfig(%q) returned error: %v", tt.profile, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("loadConfig(%q) = %v, want %v", tt.profile, got, tt.want)
+		}
+	}
+	if _, err := loadConfig(paths, "unknown"); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
+
+// TestLoadConfigErrors tests if invalid config files are rejected
+func TestLoadConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, content := range []string{
+		"defualts:\n  lines: 10\n",
+		"defaults:\n  lines:\n",
+		"defaults:\n  lines: {a: 1}\n",
+		"defaults: [",
+	} {
+		if _, err := loadConfig(writeConfigs(t, dir, content), ""); err == nil {
+			t.Errorf("expected an error for config %q", content)
+		}
+	}
+}
+
+// TestSetFlag tests if config values never override the command line
+func TestSetFlag(t *testing.T) {
+	var ext, filter string
+	var lines int
+	var rules []string
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringVar(&ext, "ext", "", "")
+	flags.StringVar(&filter, "filter", "", "")
+	flags.IntVar(&lines, "lines", 50, "")
+	flags.Int("page", 1, "")
+	flags.StringArrayVar(&rules, "redact-rule", nil, "")
+	flags.StringVar(new(string), profileFlag, "", "")
+	if err := flags.Parse([]string{"--filter", "cli"}); err != nil {
+		t.Fatal(err)
+	}
+	settings := map[string][]string{
+		"ext":         {"log", "txt"},
+		"filter":      {"config"},
+		"lines":       {"100"},
+		"redact-rule": {"a+", "b+"},
+	}
+	for key, values := range settings {
+		if err := setFlag(flags, key, values); err != nil {
+			t.Fatalf("setFlag(%s) returned error: %v", key, err)
+		}
+	}
+	if ext != "log,txt" || filter != "cli" || lines != 100 || !reflect.DeepEqual(rules, []string{"a+", "b+"}) {
+		t.Errorf("got ext=%q filter=%q lines=%d rules=%q", ext, filter, lines, rules)
+	}
+	for _, key := range []string{"unknown", profileFlag} {
+		if err := setFlag(flags, key, []string{"x"}); err == nil {
+			t.Errorf("expected an error for option %s", key)
+		}
+	}
+	if err := setFlag(flags, "page", []string{"many"}); err == nil {
+		t.Error("expected an error for an invalid number")
+	}
+}
+
+// TestLoadColors tests merging the colors of the config files
+func TestLoadColors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	paths := writeConfigs(t, dir, `
+colors:
+  match: red
+  level:
+    error: 196 bold
+    warn: yellow
+`, `
+colors:
+  match: '#ff8700'
+  terms: [red, green]
+`)
+	got, err := loadColors(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"match": "#ff8700", "level.error": "196 bold", "level.warn": "yellow", "terms": "red,green"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadColors() = %v, want %v", got, want)
+	}
+	paths = writeConfigs(t, dir, "colors:\n  match:\n")
+	if _, err := loadColors(paths); err == nil {
+		t.Error("loadColors() expected an error for an empty color")
+	}
+}
+
+// TestApplyConfigCommand tests running a command with a profile holding the flags of several commands
+func TestApplyConfigCommand(t *testing.T) {
+	dir, cleanup := withUserDir(t)
+	defer cleanup()
+	defer func(old map[string]string) { colorOverrides = old }(colorOverrides)
+	if err := os.MkdirAll(filepath.Join(dir, "config", "logy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `
+profiles:
+  m:
+    lines: 20
+    metric: [count, p95(latency)]
+    group-by: status
+    out: errors.html
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "config", "logy", "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var lines int
+	var profile, out string
+	var metrics, groupBy []string
+	root := &cobra.Command{Use: "logy", Run: func(cmd *cobra.Command, args []string) {}}
+	root.PersistentFlags().IntVar(&lines, "lines", 50, "")
+	root.PersistentFlags().StringVar(&profile, profileFlag, "", "")
+	root.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		ApplyConfig(cmd, profile)
+	}
+	agg := &cobra.Command{Use: "agg", Run: func(cmd *cobra.Command, args []string) {}}
+	agg.Flags().StringArrayVar(&metrics, "metric", nil, "")
+	agg.Flags().StringSliceVar(&groupBy, "group-by", nil, "")
+	report := &cobra.Command{Use: "report", Run: func(cmd *cobra.Command, args []string) {}}
+	report.Flags().StringVar(&out, "out", "report.html", "")
+	root.AddCommand(agg, report)
+	root.SetArgs([]string{"agg", "--profile", "m", "a.json"})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if lines != 20 || !reflect.DeepEqual(metrics, []string{"count", "p95(latency)"}) || !reflect.DeepEqual(groupBy, []string{"status"}) {
+		t.Errorf("got lines=%d metrics=%q group-by=%q", lines, metrics, groupBy)
+	}
+	// The flags of the other commands are left alone
+	if out != "report.html" {
+		t.Errorf("got out=%q, want report.html", out)
+	}
+}
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDedupe tests if consecutive duplicates are collapsed
+// both in the page offsets and in the page lines
+func TestDedupe(t *testing.T) {
+	path, cleanup := writeTestFile(t, "start\nretry\nretry\nretry\nERROR 1\nERROR 2\nend\n")
+	defer cleanup()
+
+	tests := []struct {
+		mode   string
+		pages  []int64
+		starts []int
+		lines  []pageLine
+	}{
+		{
+			"exact",
+			[]int64{0, 24, 40},
+			[]int{1, 5, 7},
+			[]pageLine{{1, "start", 1}, {2, "retry", 3}},
+		},
+		{
+			"masked",
+			[]int64{0, 24},
+			[]int{1, 5},
+			[]pageLine{{5, "ERROR 1", 2}, {7, "end", 1}},
+		},
+	}
+	for _, tt := range tests {
+		p := &Parser{lines: 2, filter: "ERROR", dedupe: tt.mode}
+		ch := make(chan stats)
+		go p.countLines(path, ch)
+		stat := <-ch
+		if !reflect.DeepEqual(stat.pages, tt.pages) || !reflect.DeepEqual(stat.starts, tt.starts) || stat.lines != 7 {
+			t.Errorf("%s: got pages %v starts %v lines %d", tt.mode, stat.pages, stat.starts, stat.lines)
+		}
+		// Collapsed matches are counted once in masked mode
+		want := 2
+		if tt.mode == "masked" {
+			want = 1
+		}
+		if stat.matches != want {
+			t.Errorf("%s: got %d matches, want %d", tt.mode, stat.matches, want)
+		}
+		page := len(tt.pages) - 1
+		if tt.mode == "exact" {
+			page = 0
+		}
+		got, err := p.readPage(path, stat.pages[page], stat.starts[page])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.lines) {
+			t.Errorf("%s: got page %v, want %v", tt.mode, got, tt.lines)
+		}
+	}
+}
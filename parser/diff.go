@@ -0,0 +1,379 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Masks for the values that change from run to run
+// Lines are compared after these values are masked
+// Unlike the pattern masks, other numbers are kept since they usually tell what the program did
+var volatileMasks = []mask{
+	isoTimeMask,
+	nginxTimeMask,
+	// Syslog timestamps are only masked here, the patterns keep the month name
+	{regexp.MustCompile(`\b[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}\b`), "<TIME>"},
+	clockTimeMask,
+	uuidMask,
+	// Process IDs like pid=123, pid 123 or sshd[123]
+	{regexp.MustCompile(`\b([Pp][Ii][Dd][=: ]\s*)\d+\b`), "${1}<PID>"},
+	{regexp.MustCompile(`\b([A-Za-z][\w.-]*)\[\d+\]`), "${1}[<PID>]"},
+	// Durations like 250ms, 1.5s or 3m20s
+	{regexp.MustCompile(`\b(?:\d+(?:\.\d+)?(?:ns|us|µs|ms|s|m|h))+\b`), "<DURATION>"},
+}
+
+// normalizeLine masks timestamps, PIDs, UUIDs and durations
+func normalizeLine(line string) string {
+	for _, m := range volatileMasks {
+		line = m.reg.ReplaceAllString(line, m.token)
+	}
+	return line
+}
+
+// differ finds the longest common subsequence of 2 lists of lines
+// using the linear space version of the Myers algorithm
+type differ struct {
+	a, b []int
+	// Tells which lines are common to both lists
+	commonA, commonB []bool
+}
+
+// newDiffer returns a differ for 2 lists of lines
+// Equal lines are replaced by the same number to compare them quickly
+func newDiffer(a, b []string) *differ {
+	ids := make(map[string]int)
+	toIDs := func(lines []string) []int {
+		res := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			res[i] = id
+		}
+		return res
+	}
+	d := &differ{a: toIDs(a), b: toIDs(b)}
+	d.commonA, d.commonB = make([]bool, len(a)), make([]bool, len(b))
+	d.diff(0, len(a), 0, len(b))
+	return d
+}
+
+// diff marks the common lines of a[aLo:aHi] and b[bLo:bHi]
+func (d *differ) diff(aLo, aHi, bLo, bHi int) {
+	// Common prefix and suffix are easy to find
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.commonA[aLo], d.commonB[bLo] = true, true
+		aLo, bLo = aLo+1, bLo+1
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi, bHi = aHi-1, bHi-1
+		d.commonA[aHi], d.commonB[bHi] = true, true
+	}
+	if aLo == aHi || bLo == bHi {
+		return
+	}
+	x, y, ok := d.bisect(aLo, aHi, bLo, bHi)
+	if !ok {
+		// Nothing in common
+		return
+	}
+	d.diff(aLo, x, bLo, y)
+	d.diff(x, aHi, y, bHi)
+}
+
+// bisect finds the middle of the shortest edit path
+// by searching from both ends at the same time
+func (d *differ) bisect(aLo, aHi, bLo, bHi int) (int, int, bool) {
+	a, b := d.a[aLo:aHi], d.b[bLo:bHi]
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// Room for the diagonals next to the last ones
+	size := 2*maxD + 2
+	// Furthest x reached on every diagonal, from the start and from the end
+	v1, v2 := make([]int, size), make([]int, size)
+	for i := range v1 {
+		v1[i], v2[i] = -1, -1
+	}
+	v1[offset+1], v2[offset+1] = 0, 0
+	delta := n - m
+	// If the total number of lines is odd the front path collides with the reverse path
+	front := delta%2 != 0
+	// Diagonals to skip because they went out of the grid
+	var k1start, k1end, k2start, k2end int
+	for step := 0; step < maxD; step++ {
+		// Walk the front path one step
+		for k1 := -step + k1start; k1 <= step-k1end; k1 += 2 {
+			k1Offset := offset + k1
+			var x1 int
+			if k1 == -step || (k1 != step && v1[k1Offset-1] < v1[k1Offset+1]) {
+				x1 = v1[k1Offset+1]
+			} else {
+				x1 = v1[k1Offset-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1, y1 = x1+1, y1+1
+			}
+			v1[k1Offset] = x1
+			switch {
+			case x1 > n:
+				k1end += 2
+			case y1 > m:
+				k1start += 2
+			case front:
+				k2Offset := offset + delta - k1
+				if k2Offset >= 0 && k2Offset < size && v2[k2Offset] != -1 {
+					// Mirror x2 onto the top left coordinate system
+					if x2 := n - v2[k2Offset]; x1 >= x2 {
+						return aLo + x1, bLo + y1, true
+					}
+				}
+			}
+		}
+		// Walk the reverse path one step
+		for k2 := -step + k2start; k2 <= step-k2end; k2 += 2 {
+			k2Offset := offset + k2
+			var x2 int
+			if k2 == -step || (k2 != step && v2[k2Offset-1] < v2[k2Offset+1]) {
+				x2 = v2[k2Offset+1]
+			} else {
+				x2 = v2[k2Offset-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2, y2 = x2+1, y2+1
+			}
+			v2[k2Offset] = x2
+			switch {
+			case x2 > n:
+				k2end += 2
+			case y2 > m:
+				k2start += 2
+			case !front:
+				k1Offset := offset + delta - k2
+				if k1Offset >= 0 && k1Offset < size && v1[k1Offset] != -1 {
+					x1 := v1[k1Offset]
+					y1 := offset + x1 - k1Offset
+					if x1 >= n-x2 {
+						return aLo + x1, bLo + y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// readLines reads all lines of a file
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open file path %s, Error: %v", path, err)
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, scanBuf)
+	var lines []string
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("Diff lines error: %v", err)
+	}
+	return lines, nil
+}
+
+// Number of header lines naming the files at the top of a diff
+// The hunks always come after them
+const diffHeaderLines = 2
+
+// writeDiff writes the unified diff of 2 files with the given number of context lines
+// Common lines are printed as they are in the first file
+// It returns the number of changed lines
+func writeDiff(w io.Writer, pathA, pathB string, a, b []string, unified int) int {
+	norm := func(lines []string) []string {
+		res := make([]string, len(lines))
+		for i, line := range lines {
+			res[i] = normalizeLine(line)
+		}
+		return res
+	}
+	d := newDiffer(norm(a), norm(b))
+	// The edit script: common lines are ' ', removed lines '-' and added lines '+'
+	type edit struct {
+		kind byte
+		i, j int
+	}
+	var edits []edit
+	var changes int
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case i < len(a) && !d.commonA[i]:
+			edits = append(edits, edit{'-', i, j})
+			i++
+			changes++
+		case j < len(b) && !d.commonB[j]:
+			edits = append(edits, edit{'+', i, j})
+			j++
+			changes++
+		default:
+			edits = append(edits, edit{' ', i, j})
+			i, j = i+1, j+1
+		}
+	}
+	if changes == 0 {
+		return 0
+	}
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", pathA, pathB)
+	for start := 0; start < len(edits); {
+		// Find the next change
+		for start < len(edits) && edits[start].kind == ' ' {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		// A hunk goes on while changes are close enough to share their context
+		end := start
+		for k := start; k < len(edits); k++ {
+			if edits[k].kind != ' ' {
+				end = k + 1
+			} else if k-end >= 2*unified {
+				break
+			}
+		}
+		from, to := start-unified, end+unified
+		if from < 0 {
+			from = 0
+		}
+		if to > len(edits) {
+			to = len(edits)
+		}
+		var linesA, linesB int
+		for _, e := range edits[from:to] {
+			if e.kind != '+' {
+				linesA++
+			}
+			if e.kind != '-' {
+				linesB++
+			}
+		}
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(edits[from].i, linesA), hunkRange(edits[from].j, linesB))
+		for _, e := range edits[from:to] {
+			switch e.kind {
+			case '-':
+				fmt.Fprintf(w, "-%s\n", a[e.i])
+			case '+':
+				fmt.Fprintf(w, "+%s\n", b[e.j])
+			default:
+				fmt.Fprintf(w, " %s\n", a[e.i])
+			}
+		}
+		start = to
+	}
+	return changes
+}
+
+// hunkRange formats the line range of a hunk header
+// Empty ranges point at the line before them, just like diff -u
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// colorDiff colors a line of a unified diff by its kind
+// Only header lines are file names, removed lines can start with -- too
+func colorDiff(raw, text string, header bool) string {
+	switch {
+	case header:
+		return info(text)
+	case strings.HasPrefix(raw, "@@"):
+		return currentTheme.get("diff.hunk").sprint(text)
+	case strings.HasPrefix(raw, "+"):
+		return currentTheme.get("diff.added").sprint(text)
+	case strings.HasPrefix(raw, "-"):
+		return currentTheme.get("diff.removed").sprint(text)
+	}
+	return text
+}
+
+// writeDiffFile writes the unified diff of 2 files to a new file in the given folder
+// It returns the path of the new file and the number of changed lines
+func writeDiffFile(dir, pathA, pathB string, unified int) (string, int, error) {
+	a, err := readLines(pathA)
+	if err != nil {
+		return "", 0, err
+	}
+	b, err := readLines(pathB)
+	if err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s..%s.diff", filepath.Base(pathA), filepath.Base(pathB)))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("Cannot create diff file: %v", err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	changes := writeDiff(w, pathA, pathB, a, b, unified)
+	if err := w.Flush(); err != nil {
+		return "", 0, fmt.Errorf("Cannot write diff file: %v", err)
+	}
+	return path, changes, nil
+}
+
+// Diff shows the unified diff of 2 files ignoring the values that change from run to run
+// The diff is browsed with the same navigation as any other file
+func Diff(pathA, pathB string, opts Options, unified int) {
+	if unified < 0 {
+		exitWithError("Error! Option flag -unified cannot be negative")
+	}
+	// Grep compatible output exits right away, which would leave the diff file behind
+	if opts.grepMode() != grepOff {
+		exitWithError("Error! Option flags -grep, -only-matching, -count, -files-with-matches and -files-without-match cannot be used with diff")
+	}
+	for _, path := range []string{pathA, pathB} {
+		info, err := os.Stat(path)
+		if err != nil {
+			fatalf("Cannot get file stat info for %s. Error: %v", path, err)
+		}
+		if info.IsDir() {
+			exitWithError("Error! Only files can be compared")
+		}
+	}
+	// The diff is written to a temporary file so huge diffs can be paginated
+	dir, err := ioutil.TempDir("", "logy-diff")
+	if err != nil {
+		fatal("Cannot create diff file:", err)
+	}
+	defer os.RemoveAll(dir)
+	// Errors exit without running the deferred calls
+	defer onExit(func() { os.RemoveAll(dir) })()
+	path, changes, err := writeDiffFile(dir, pathA, pathB, unified)
+	if err != nil {
+		fatal(err)
+	}
+	if changes == 0 {
+		fmt.Println(info("The files have no differences"))
+		return
+	}
+	// Directory options do not apply to the diff file
+	opts.Ext, opts.Merge = "", false
+	p := New(path, opts)
+	p.diff = true
+	p.Parse()
+}
@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// TestNormalizeLine tests if the values that change from run to run are masked
+func TestNormalizeLine(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"2019-12-10T15:04:05Z started worker pid=4021", "<TIME> started worker pid=<PID>"},
+		{"Dec 10 15:04:05 host sshd[123]: ok", "<TIME> host sshd[<PID>]: ok"},
+		{"request 3f2b8c1e-9a4d-4e2f-8b1a-0c9d8e7f6a5b took 250ms", "request <UUID> took <DURATION>"},
+		{"retry in 1m30s after 3 attempts", "retry in <DURATION> after 3 attempts"},
+		{"no volatile parts 42", "no volatile parts 42"},
+	}
+	for _, tt := range tests {
+		if got := normalizeLine(tt.in); got != tt.want {
+			t.Errorf("normalizeLine(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestWriteDiff tests the unified diff of 2 lists of lines
+func TestWriteDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    []string
+		unified int
+		changes int
+		want    string
+	}{
+		{
+			name:    "same after masking",
+			a:       []string{"10:00:00 start pid=1", "done in 2s"},
+			b:       []string{"11:30:00 start pid=2", "done in 40ms"},
+			unified: 3,
+		},
+		{
+			name:    "changed line",
+			a:       []string{"a", "b", "c", "d", "e"},
+			b:       []string{"a", "b", "x", "d", "e"},
+			unified: 1,
+			changes: 2,
+			want:    "--- a\n+++ b\n@@ -2,3 +2,3 @@\n b\n-c\n+x\n d\n",
+		},
+		{
+			name:    "context comes from the first file",
+			a:       []string{"10:00:00 start", "old"},
+			b:       []string{"12:00:00 start", "new", "more"},
+			unified: 3,
+			changes: 3,
+			want:    "--- a\n+++ b\n@@ -1,2 +1,3 @@\n 10:00:00 start\n-old\n+new\n+more\n",
+		},
+		{
+			name:    "separate hunks",
+			a:       []string{"1", "2", "3", "4", "5", "6", "7"},
+			b:       []string{"0", "1", "2", "3", "4", "5", "6"},
+			unified: 1,
+			changes: 2,
+			want:    "--- a\n+++ b\n@@ -1 +1,2 @@\n+0\n 1\n@@ -6,2 +7 @@\n 6\n-7\n",
+		},
+		{
+			name:    "empty first file",
+			b:       []string{"x"},
+			unified: 3,
+			changes: 1,
+			want:    "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n",
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		changes := writeDiff(&buf, "a", "b", tt.a, tt.b, tt.unified)
+		if changes != tt.changes {
+			t.Errorf("%s: got %d changes, want %d", tt.name, changes, tt.changes)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: got diff\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestDiffer tests if the common lines are the longest common subsequence
+func TestDiffer(t *testing.T) {
+	a := []string{"a", "b", "c", "a", "b", "b", "a"}
+	b := []string{"c", "b", "a", "b", "a", "c"}
+	d := newDiffer(a, b)
+	var common []string
+	for i, ok := range d.commonA {
+		if ok {
+			common = append(common, a[i])
+		}
+	}
+	// The longest common subsequence of both lists has 4 lines
+	if len(common) != 4 {
+		t.Errorf("got %d common lines %v, want 4", len(common), common)
+	}
+	var fromB []string
+	for j, ok := range d.commonB {
+		if ok {
+			fromB = append(fromB, b[j])
+		}
+	}
+	if len(fromB) != len(common) {
+		t.Fatalf("got %d common lines in b, want %d", len(fromB), len(common))
+	}
+	for i := range common {
+		if common[i] != fromB[i] {
+			t.Errorf("common line %d is %q in a and %q in b", i, common[i], fromB[i])
+		}
+	}
+}
+
+// TestColorDiff tests if only the header lines are colored as file names
+func TestColorDiff(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	defer func() { color.NoColor = noColor }()
+
+	removed := currentTheme.get("diff.removed")
+	tests := []struct {
+		raw    string
+		header bool
+		want   string
+	}{
+		{"--- a.log", true, info("--- a.log")},
+		{"+++ b.log", true, info("+++ b.log")},
+		// A removed line that starts with -- is not a header
+		{"--- separator", false, removed.sprint("--- separator")},
+		{"-old", false, removed.sprint("-old")},
+		{"+new", false, currentTheme.get("diff.added").sprint("+new")},
+		{"@@ -1 +1 @@", false, currentTheme.get("diff.hunk").sprint("@@ -1 +1 @@")},
+		{" same", false, " same"},
+	}
+	for _, tt := range tests {
+		if got := colorDiff(tt.raw, tt.raw, tt.header); got != tt.want {
+			t.Errorf("colorDiff(%q, %v) = %q, want %q", tt.raw, tt.header, got, tt.want)
+		}
+	}
+}
+
+// TestWriteDiffFile tests if errors are returned instead of exiting
+func TestWriteDiffFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+	if err := ioutil.WriteFile(a, []byte("start\nold\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte("start\nnew\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path, changes, err := writeDiffFile(dir, a, b, 3)
+	if err != nil || changes != 2 {
+		t.Fatalf("got %d changes, error %v", changes, err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "--- " + a + "\n+++ " + b + "\n@@ -1,2 +1,2 @@\n start\n-old\n+new\n"; string(data) != want {
+		t.Errorf("got diff\n%s\nwant\n%s", data, want)
+	}
+	if _, _, err := writeDiffFile(dir, a, filepath.Join(dir, "missing.log"), 3); err == nil || !strings.Contains(err.Error(), "missing.log") {
+		t.Errorf("got error %v", err)
+	}
+}
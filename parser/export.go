@@ -0,0 +1,202 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// exportLine is a line kept around to be written as context
+type exportLine struct {
+	number int
+	text   []byte
+}
+
+// exporter writes the selected lines of a file with optional context
+type exporter struct {
+	p *Parser
+	w io.Writer
+	// Lines seen before the next selected line
+	before []exportLine
+	// Number of context lines still to write after a selected line
+	after int
+	// Number of the last written line
+	last int
+	// Number of lines written so far
+	written int
+	// Only the lines between these offsets can be selected
+	// A negative end means the end of file
+	lo, hi int64
+}
+
+// exportTo writes all matching lines of the given pages of the files to a new file
+// The pages go from one to the other included, a last page of 0 means the last page of every file
+// The file is compressed with gzip when asked or when its name ends with .gz
+// It returns the number of matching lines that were written
+func (p *Parser) exportTo(path string, fs []stats, from, to int) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("Error! Cannot create export file: %v", err)
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	var w io.Writer = bw
+	var gz *gzip.Writer
+	if p.compress || strings.HasSuffix(path, ".gz") {
+		gz = gzip.NewWriter(bw)
+		w = gz
+	}
+	var total int
+	for _, stat := range fs {
+		// Tell where the lines come from when there are more files
+		if len(fs) > 1 {
+			fmt.Fprintf(w, "==> %s <==\n", stat.path)
+		}
+		total += p.exportFile(w, stat, from, to)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return 0, fmt.Errorf("Error! Cannot compress export file: %v", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, fmt.Errorf("Error! Cannot write export file: %v", err)
+	}
+	return total, nil
+}
+
+// exportFile writes the matching lines of a file
+// Only the displayed pages of the page range are exported
+// Only the pages with matches (and their neighbours when context is needed) are read
+func (p *Parser) exportFile(w io.Writer, stat stats, from, to int) int {
+	numPages := len(stat.offsets)
+	// Files that do not have the requested pages have nothing to export
+	if from > numPages {
+		return 0
+	}
+	if to == 0 || to > numPages {
+		to = numPages
+	}
+	// The pages with matches are the displayed ones
+	hit := make(map[int64]bool, to-from+1)
+	for _, offset := range stat.offsets[from-1 : to] {
+		hit[offset] = true
+	}
+	e := &exporter{p: p, w: w, lo: stat.offsets[from-1], hi: -1}
+	// The range ends where the page after its last page starts
+	last := stat.offsets[to-1]
+	if i := sort.Search(len(stat.pages), func(i int) bool { return stat.pages[i] > last }); i < len(stat.pages) {
+		e.hi = stat.pages[i]
+	}
+	// Context lines can live on the neighbour pages
+	reach := (p.context + p.lines - 1) / p.lines
+	need := make([]bool, len(stat.pages))
+	for i, offset := range stat.pages {
+		if !hit[offset] {
+			continue
+		}
+		for j := i - reach; j <= i+reach; j++ {
+			if j >= 0 && j < len(stat.pages) {
+				need[j] = true
+			}
+		}
+	}
+	// Open the file
+	f, err := os.Open(stat.path)
+	if err != nil {
+		fatalf("Cannot open file path %s, Error: %v", stat.path, err)
+	}
+	defer f.Close()
+
+	var selected int
+	for i := 0; i < len(stat.pages); i++ {
+		if !need[i] {
+			continue
+		}
+		// Find where this run of consecutive pages ends
+		// The last page ends at the end of file
+		j := i
+		for j+1 < len(stat.pages) && need[j+1] {
+			j++
+		}
+		end := int64(-1)
+		if j+1 < len(stat.pages) {
+			end = stat.pages[j+1]
+		}
+		selected += e.run(f, stat.pages[i], end, stat.starts[i])
+		i = j
+	}
+	return selected
+}
+
+// run writes the selected lines between two offsets
+// A negative end offset means the end of file
+func (e *exporter) run(f *os.File, offset, end int64, number int) int {
+	// Navigate to the start of the run
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		fatal("Cannot seek file page:", err)
+	}
+	r := bufio.NewReader(f)
+	// Lines from the previous run are not next to these ones
+	e.before, e.after = nil, 0
+	var selected int
+	for position := offset; end < 0 || position < end; number++ {
+		line, err := r.ReadBytes('\n')
+		lineOffset := position
+		position += int64(len(line))
+		if len(line) > 0 {
+			line = bytes.TrimRight(line, "\r\n")
+			// Context lines can be outside of the page range but matches cannot
+			inRange := lineOffset >= e.lo && (e.hi < 0 || lineOffset < e.hi)
+			switch {
+			case e.p.excluded(line):
+				// Excluded lines are not even used as context
+			case inRange && (e.p.filter == "" || len(e.p.matchSpans(line)) > 0):
+				selected++
+				e.write(exportLine{number: number, text: line}, true)
+			case e.after > 0:
+				e.after--
+				e.write(exportLine{number: number, text: line}, false)
+			case e.p.context > 0:
+				// Keep the line in case the next ones are selected
+				e.before = append(e.before, exportLine{number: number, text: line})
+				if len(e.before) > e.p.context {
+					e.before = e.before[1:]
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fatal("Export lines error:", err)
+		}
+	}
+	return selected
+}
+
+// write sends a line to the export writer
+// Selected lines bring their context before them
+func (e *exporter) write(line exportLine, selected bool) {
+	lines := []exportLine{line}
+	if selected {
+		lines = append(e.before, line)
+		e.before = nil
+		e.after = e.p.context
+	}
+	for _, l := range lines {
+		// Separate groups of lines that are not next to each other, just like grep
+		if e.p.context > 0 && e.written > 0 && l.number > e.last+1 {
+			io.WriteString(e.w, "--\n")
+		}
+		io.WriteString(e.w, e.p.redact(string(l.text)))
+		io.WriteString(e.w, "\n")
+		e.last = l.number
+		e.written++
+	}
+}
@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestExportTo tests if the matching lines of the page range are written with their context
+func TestExportTo(t *testing.T) {
+	// 6 pages of 2 lines, the pages 1, 3, 5 and 6 have matches
+	path, cleanup := writeTestLog(t, 12, func(i int) string {
+		if i == 2 || i == 5 || i == 9 || i == 11 {
+			return "ERROR"
+		}
+		return "INFO"
+	})
+	defer cleanup()
+	dir := filepath.Dir(path)
+
+	tests := []struct {
+		name    string
+		pages   string
+		context int
+		out     string
+		total   int
+		want    []string
+	}{
+		{
+			"all pages",
+			"", 0, "all.log", 4,
+			[]string{"ERROR line 2", "ERROR line 5", "ERROR line 9", "ERROR line 11"},
+		},
+		{
+			// The pages are the displayed ones, so 2-3 are the pages with the lines 5 and 9
+			"page range",
+			"2-3", 0, "range.log", 2,
+			[]string{"ERROR line 5", "ERROR line 9"},
+		},
+		{
+			// Context lines can be outside of the range but the matches there are not exported
+			"page range with context",
+			"2-3", 1, "context.log", 2,
+			[]string{"INFO line 4", "ERROR line 5", "INFO line 6", "--", "INFO line 8", "ERROR line 9", "INFO line 10"},
+		},
+		{
+			"open range compressed",
+			"4-", 0, "open.log.gz", 1,
+			[]string{"ERROR line 11"},
+		},
+		{
+			"range after the last page",
+			"5-", 0, "none.log", 0,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		p := New(path, Options{Text: "plain", Filter: "ERROR", Lines: 2, Page: 1, NoColor: true, Pages: tt.pages, Context: tt.context})
+		out := filepath.Join(dir, tt.out)
+		total, err := p.exportTo(out, visibleStats(p.collectStats()), p.from, p.to)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if total != tt.total {
+			t.Errorf("%s: got %d exported matches, want %d", tt.name, total, tt.total)
+		}
+		f, err := os.Open(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data []byte
+		if strings.HasSuffix(out, ".gz") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			data, err = ioutil.ReadAll(gz)
+		} else {
+			data, err = ioutil.ReadAll(f)
+		}
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		want := strings.Join(tt.want, "\n")
+		if want != "" {
+			want += "\n"
+		}
+		if string(data) != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, data, want)
+		}
+	}
+	// The export command of the prompt ignores the page range
+	p := New(path, Options{Text: "plain", Filter: "ERROR", Lines: 2, Page: 3, NoColor: true, Pages: "3"})
+	fs := visibleStats(p.collectStats())
+	n := &navigator{p: p, all: fs, fs: fs, id: 1, page: 3}
+	out := filepath.Join(dir, "prompt.log")
+	if err := n.run(command{action: actionExport, path: out}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ERROR line 2\nERROR line 5\nERROR line 9\nERROR line 11\n"; string(data) != want {
+		t.Errorf("prompt export: got\n%s\nwant\n%s", data, want)
+	}
+}
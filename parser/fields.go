@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Accepted formats of structured lines
+// Auto detects the format of every line
+var fieldFormats = []string{
+	"auto",
+	"json",
+	"logfmt",
+	"nginx",
+}
+
+// Nginx combined log format, optionally followed by the request time
+var nginxReg = regexp.MustCompile(`^(\S+) \S+ (\S+) \[([^\]]+)\] "(\S+) (\S+) ?(\S*)" (\d{3}) (\d+|-) "([^"]*)" "([^"]*)"(?: (\S+))?`)
+
+// Names of the nginx fields, in the order of the regex groups
+// They are the names of the nginx variables
+var nginxFields = []string{
+	"remote_addr",
+	"remote_user",
+	"time_local",
+	"request_method",
+	"request_uri",
+	"server_protocol",
+	"status",
+	"body_bytes_sent",
+	"http_referer",
+	"http_user_agent",
+	"request_time",
+}
+
+// extractFields parses the fields of a structured line
+// It returns nil when the line has no fields in the given format
+func extractFields(line, format string) map[string]interface{} {
+	switch format {
+	case "json":
+		return jsonFields(line)
+	case "logfmt":
+		return logfmtFields(line)
+	case "nginx":
+		return nginxLineFields(line)
+	}
+	// Guess the format from the line
+	if strings.Contains(line, "{") {
+		if fields := jsonFields(line); fields != nil {
+			return fields
+		}
+	}
+	if fields := nginxLineFields(line); fields != nil {
+		return fields
+	}
+	return logfmtFields(line)
+}
+
+// jsonFields parses the first JSON object found in the line
+func jsonFields(line string) map[string]interface{} {
+	for _, m := range findJSON(line) {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(line[m[0]:m[1]]), &fields); err == nil && len(fields) > 0 {
+			return fields
+		}
+	}
+	return nil
+}
+
+// nginxLineFields parses a line in the nginx combined log format
+func nginxLineFields(line string) map[string]interface{} {
+	m := nginxReg.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	fields := make(map[string]interface{}, len(nginxFields))
+	for i, name := range nginxFields {
+		if m[i+1] != "" {
+			fields[name] = m[i+1]
+		}
+	}
+	return fields
+}
+
+// logfmtFields parses the key=value pairs of a line
+// Values can be quoted to contain spaces. Words without "=" are ignored
+func logfmtFields(line string) map[string]interface{} {
+	var fields map[string]interface{}
+	for i := 0; i < len(line); {
+		// Skip the spaces before the key
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' {
+			i++
+		}
+		key := line[start:i]
+		if i >= len(line) || line[i] != '=' || key == "" {
+			// Skip a lonely "=" too
+			if i < len(line) && line[i] == '=' {
+				i++
+			}
+			continue
+		}
+		// Skip the "="
+		i++
+		var value string
+		if i < len(line) && line[i] == '"' {
+			// Quoted values end at the next unescaped quote
+			var b strings.Builder
+			for i++; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				b.WriteByte(line[i])
+			}
+			// Skip the closing quote
+			i++
+			value = b.String()
+		} else {
+			start = i
+			for i < len(line) && line[i] != ' ' {
+				i++
+			}
+			value = line[start:i]
+		}
+		if fields == nil {
+			fields = make(map[string]interface{})
+		}
+		fields[key] = value
+	}
+	return fields
+}
+
+// fieldString formats a field value the way it looks in the line
+// JSON numbers are printed without exponent, e.g. 1000000 instead of 1e+06
+func fieldString(v interface{}) string {
+	if n, ok := v.(float64); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// fieldValue finds a field by name
+// Nested JSON fields are separated by dots, e.g. http.status
+// The second value is false when the field does not exist
+func fieldValue(fields map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := fields[name]; ok {
+		return v, true
+	}
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) < 2 {
+		return nil, false
+	}
+	nested, ok := fields[parts[0]].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return fieldValue(nested, parts[1])
+}
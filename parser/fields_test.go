@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestExtractFields tests if the fields of structured lines are parsed
+func TestExtractFields(t *testing.T) {
+	tests := []struct {
+		line   string
+		format string
+		want   map[string]interface{}
+	}{
+		{
+			`INFO {"status":200,"http":{"method":"GET"}}`,
+			"auto",
+			map[string]interface{}{"status": 200.0, "http": map[string]interface{}{"method": "GET"}},
+		},
+		{
+			`req {} {"status":200} done in 5ms {"retry":true}`,
+			"json",
+			map[string]interface{}{"status": 200.0},
+		},
+		{
+			`level=info msg="request done" path=/api status=500 =x word`,
+			"auto",
+			map[string]interface{}{"level": "info", "msg": "request done", "path": "/api", "status": "500"},
+		},
+		{
+			`msg="say \"hi\""`,
+			"logfmt",
+			map[string]interface{}{"msg": `say "hi"`},
+		},
+		{
+			`10.0.0.1 - bob [10/Dec/2019:15:04:05 +0000] "GET /api?x=1 HTTP/1.1" 404 12 "-" "curl/8.0" 0.025`,
+			"auto",
+			map[string]interface{}{
+				"remote_addr":     "10.0.0.1",
+				"remote_user":     "bob",
+				"time_local":      "10/Dec/2019:15:04:05 +0000",
+				"request_method":  "GET",
+				"request_uri":     "/api?x=1",
+				"server_protocol": "HTTP/1.1",
+				"status":          "404",
+				"body_bytes_sent": "12",
+				"http_referer":    "-",
+				"http_user_agent": "curl/8.0",
+				"request_time":    "0.025",
+			},
+		},
+		{
+			"just some text",
+			"auto",
+			nil,
+		},
+		{
+			"a=1",
+			"json",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		if got := extractFields(tt.line, tt.format); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("extractFields(%q, %q) = %v, want %v", tt.line, tt.format, got, tt.want)
+		}
+	}
+}
+
+// TestFieldValue tests if nested fields are found
+func TestFieldValue(t *testing.T) {
+	fields := map[string]interface{}{
+		"a.b":  "flat",
+		"http": map[string]interface{}{"status": 200.0},
+	}
+	if v, ok := fieldValue(fields, "a.b"); !ok || v != "flat" {
+		t.Errorf("got %v, %v for a.b", v, ok)
+	}
+	if v, ok := fieldValue(fields, "http.status"); !ok || fieldString(v) != "200" {
+		t.Errorf("got %v, %v for http.status", v, ok)
+	}
+	if _, ok := fieldValue(fields, "http.method"); ok {
+		t.Errorf("found missing field http.method")
+	}
+}
@@ -0,0 +1,240 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// filterResult holds the file stats computed for a new filter
+type filterResult struct {
+	// Parser configured with the new filter
+	p *Parser
+	// Stats for all files
+	all []stats
+	// Histogram of the matches (when enabled)
+	hist *histogram
+	// Sequence number of the filter request
+	seq int
+	// Error raised while matching the files
+	err error
+}
+
+// rematchResult holds the stats of a file matched against a new filter
+type rematchResult struct {
+	stat stats
+	err  error
+}
+
+// compileFilter compiles the filter as a regex if regex support is enabled
+// Regex support is enabled only for filters of length greater than 1
+// If regex remains enabled when filter lenght is 1, strange output is given
+// Also there is no sense in having a regex with length of 1
+func compileFilter(filter string, withRegex bool) (*regexp.Regexp, error) {
+	if !withRegex || len(filter) <= 1 {
+		return nil, nil
+	}
+	return regexp.Compile(filter)
+}
+
+// filtered tells if the pages are filtered by the user input
+func (p *Parser) filtered() bool {
+	return p.filter != "" || p.exclude != ""
+}
+
+// excluded determines if a line must be hidden from the output
+func (p *Parser) excluded(line []byte) bool {
+	if p.exclude == "" {
+		return false
+	}
+	if p.exReg != nil {
+		return p.exReg.Match(line)
+	}
+	return bytes.Contains(line, []byte(p.exclude))
+}
+
+// withFilter returns a copy of the parser that uses the given filter and exclusion
+func (p *Parser) withFilter(filter, exclude string) (*Parser, error) {
+	regex, err := compileFilter(filter, p.withRegex)
+	if err != nil {
+		return nil, fmt.Errorf("Regex parse error: %s", err.Error())
+	}
+	exReg, err := compileFilter(exclude, p.withRegex)
+	if err != nil {
+		return nil, fmt.Errorf("Regex parse error: %s", err.Error())
+	}
+	np := *p
+	np.filter, np.regex, np.terms = filter, regex, filterTerms(filter, regex)
+	np.exclude, np.exReg = exclude, exReg
+	return &np, nil
+}
+
+// rematch computes the stats for all files using the current filter
+// The page offsets that were already computed are reused
+// so the lines do not need to be counted again
+// Only the first error is returned
+func (p *Parser) rematch(all []stats) ([]stats, error) {
+	// Channel to receive all file stats
+	sc := make(chan rematchResult)
+	// Match all files concurrently
+	for _, stat := range all {
+		go func(stat stats) {
+			res, err := p.rematchFile(stat)
+			sc <- rematchResult{res, err}
+		}(stat)
+	}
+	// Stats are indexed by path to keep the original file order
+	received := make(map[string]stats, len(all))
+	var err error
+	for range all {
+		res := <-sc
+		if res.err != nil && err == nil {
+			err = res.err
+		}
+		received[res.stat.path] = res.stat
+	}
+	if err != nil {
+		return nil, err
+	}
+	fs := make([]stats, 0, len(all))
+	for _, stat := range all {
+		fs = append(fs, received[stat.path])
+	}
+
+	return fs, nil
+}
+
+// rematchFile matches every page of a file against the current filter
+func (p *Parser) rematchFile(stat stats) (stats, error) {
+	// Open the file
+	f, err := os.Open(stat.path)
+	if err != nil {
+		return stats{path: stat.path}, fmt.Errorf("Cannot open file path %s, Error: %v", stat.path, err)
+	}
+	defer f.Close()
+	// Start a new reader
+	r := bufio.NewReader(f)
+	// The new stats reuse the page offsets
+	res := stats{path: stat.path, pages: stat.pages, starts: stat.starts, lines: stat.lines}
+	// This is the position of the reader in the file
+	var position int64
+	// In dedupe mode this is the key of the previous line
+	var lastKey string
+	for i, offset := range stat.pages {
+		// The page ends where the next one starts
+		// The last page ends at the end of file
+		end := int64(-1)
+		if i+1 < len(stat.pages) {
+			end = stat.pages[i+1]
+		}
+		// This is used to know if page has been hit (matched)
+		var pageHit bool
+		// This is used to count the number of matches on the page
+		var pageMatches int
+		for position = offset; end < 0 || position < end; {
+			line, err := r.ReadBytes('\n')
+			position += int64(len(line))
+			// Duplicates are not counted again in dedupe mode
+			if p.dedupe != "" && len(line) > 0 {
+				key := p.dedupeKey(line)
+				duplicate := position > int64(len(line)) && key == lastKey
+				lastKey = key
+				if duplicate {
+					continue
+				}
+			}
+			if numHits := p.lineHits(line); numHits > 0 {
+				pageHit = true
+				pageMatches += numHits
+			}
+			// If only an exclusion was provided
+			// every line that is not excluded is a page hit
+			if p.filter == "" && len(line) > 0 && !p.excluded(line) {
+				pageHit = true
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return res, fmt.Errorf("Match lines error: %v", err)
+			}
+		}
+		res.matches += pageMatches
+		if !p.filtered() || pageHit {
+			res.offsets = append(res.offsets, offset)
+			res.hits = append(res.hits, pageMatches)
+		}
+	}
+	return res, nil
+}
+
+// refilter starts matching the files against a new filter in the background
+// The result is sent on the given channel when it is ready
+func (n *navigator) refilter(cmd command, ch chan filterResult) {
+	filter, exclude := n.p.filter, n.p.exclude
+	if cmd.action == actionFilter {
+		filter = cmd.text
+	} else {
+		exclude = cmd.text
+	}
+	np, err := n.p.withFilter(filter, exclude)
+	if err != nil {
+		fmt.Printf("\n%s\n\n", fail(err.Error()))
+		return
+	}
+	// Newer requests make older ones obsolete
+	n.seq++
+	seq := n.seq
+	all := n.all
+	go func() {
+		res := filterResult{p: np, seq: seq}
+		if res.all, res.err = np.rematch(all); res.err == nil && np.histogram {
+			res.hist = np.matchHistogram(visibleStats(res.all))
+		}
+		ch <- res
+	}()
+	fmt.Printf("\n%s\n\n", info("Applying the new filter in the background..."))
+}
+
+// applyFilter switches the navigator to the stats computed for a new filter
+// The current file and position are kept when possible
+func (n *navigator) applyFilter(res filterResult) error {
+	// Ignore results for filters that were replaced in the meantime
+	if res.seq != n.seq {
+		return nil
+	}
+	if res.err != nil {
+		return res.err
+	}
+	fs := visibleStats(res.all)
+	if len(fs) == 0 {
+		return errors.New("Sorry. Nothing matches the new filter. The previous filter is kept")
+	}
+	// Remember where we are to find the closest page afterwards
+	current := n.current()
+	offset := current.offsets[n.page-1]
+	n.p, n.all, n.fs, n.hist = res.p, res.all, fs, res.hist
+	n.id, n.page = 1, 1
+	for i, stat := range fs {
+		if stat.path != current.path {
+			continue
+		}
+		n.id = i + 1
+		// Go to the first page at or after the current position
+		n.page = len(stat.offsets)
+		for j, o := range stat.offsets {
+			if o >= offset {
+				n.page = j + 1
+				break
+			}
+		}
+		break
+	}
+	fmt.Printf("\n\n")
+	n.show()
+	return nil
+}
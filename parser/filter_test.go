@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// writeFilterLog writes a log with 6 pages of 2 lines
+// The pages 1 and 5 have errors, 3 has a warning and 6 has debug lines, 2 and 4 only have info lines
+func writeFilterLog(t *testing.T) (string, func()) {
+	return writeTestLog(t, 12, func(i int) string {
+		switch i {
+		case 2, 9:
+			return "ERROR"
+		case 5:
+			return "WARN"
+		case 11, 12:
+			return "DEBUG"
+		}
+		return "INFO"
+	})
+}
+
+// TestRematch tests if the pages are matched again with a new filter and exclusion
+func TestRematch(t *testing.T) {
+	path, cleanup := writeFilterLog(t)
+	defer cleanup()
+	p := New(path, Options{Text: "plain", Lines: 2, Page: 1, NoColor: true})
+	all := p.collectStats()
+
+	tests := []struct {
+		name    string
+		filter  string
+		exclude string
+		offsets []int64
+		hits    []int
+		matches int
+	}{
+		{"no filter", "", "", all[0].pages, []int{0, 0, 0, 0, 0, 0}, 0},
+		{"filter", "ERROR", "", []int64{all[0].pages[0], all[0].pages[4]}, []int{1, 1}, 2},
+		// Every page with a line that is not excluded is shown
+		{"exclusion only", "", "INFO", []int64{all[0].pages[0], all[0].pages[2], all[0].pages[4], all[0].pages[5]}, []int{0, 0, 0, 0}, 0},
+		// Excluded lines are never matches
+		{"filter and exclusion", "ERROR", "line 9", []int64{all[0].pages[0]}, []int{1}, 1},
+		{"nothing matches", "FATAL", "", nil, nil, 0},
+	}
+	for _, tt := range tests {
+		np, err := p.withFilter(tt.filter, tt.exclude)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		fs, err := np.rematch(all)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := fs[0]
+		if !reflect.DeepEqual(got.offsets, tt.offsets) || !reflect.DeepEqual(got.hits, tt.hits) || got.matches != tt.matches {
+			t.Errorf("%s: got offsets %v, hits %v and %d matches, want %v, %v and %d", tt.name, got.offsets, got.hits, got.matches, tt.offsets, tt.hits, tt.matches)
+		}
+		// The page offsets are reused
+		if !reflect.DeepEqual(got.pages, all[0].pages) || got.lines != 12 {
+			t.Errorf("%s: got pages %v and %d lines", tt.name, got.pages, got.lines)
+		}
+	}
+
+	// Files that cannot be read anymore are reported
+	missing := stats{path: path + ".missing", pages: []int64{0}, starts: []int{1}}
+	if _, err := p.rematch(append(all, missing)); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+// TestApplyFilter tests if the position is kept after a filter change
+// It moves to the next page left or to the last one when there is none
+func TestApplyFilter(t *testing.T) {
+	path, cleanup := writeFilterLog(t)
+	defer cleanup()
+
+	tests := []struct {
+		name        string
+		filter      string
+		exclude     string
+		page        int
+		input       string
+		want        int
+		wantFilter  string
+		wantExclude string
+		fail        bool
+	}{
+		// The current page has a match and stays
+		{"kept", "", "", 5, "/ERROR", 2, "ERROR", "", false},
+		// The closest page after the current one is shown
+		{"next page", "", "", 2, "/ERROR", 2, "ERROR", "", false},
+		{"exclusion", "", "", 4, "/!INFO", 3, "", "INFO", false},
+		// There is no match after the last page so the position is clamped
+		{"clamped", "", "", 6, "/ERROR", 2, "ERROR", "", false},
+		// Clearing the filter shows all pages again
+		{"filter cleared", "ERROR", "", 2, "/", 5, "", "", false},
+		{"exclusion cleared", "", "INFO", 2, "/!", 3, "", "", false},
+		// Nothing matches so the previous filter and page are kept
+		{"no match", "ERROR", "", 2, "/FATAL", 2, "ERROR", "", true},
+	}
+	for _, tt := range tests {
+		p := New(path, Options{Text: "plain", Filter: tt.filter, Exclude: tt.exclude, Lines: 2, Page: 1, NoColor: true})
+		all := p.collectStats()
+		n := &navigator{p: p, all: all, fs: visibleStats(all), id: 1, page: tt.page}
+		// The filter is typed at the prompt
+		cmd, err := extractNavigation(tt.input)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		ch := make(chan filterResult, 1)
+		n.refilter(cmd, ch)
+		err = n.applyFilter(<-ch)
+		if (err != nil) != tt.fail {
+			t.Fatalf("%s: expected failure %v; got error %v", tt.name, tt.fail, err)
+		}
+		if n.id != 1 || n.page != tt.want || n.p.filter != tt.wantFilter || n.p.exclude != tt.wantExclude {
+			t.Errorf("%s: got page %d,%d with filter %q and exclusion %q, want page 1,%d with filter %q and exclusion %q",
+				tt.name, n.id, n.page, n.p.filter, n.p.exclude, tt.want, tt.wantFilter, tt.wantExclude)
+		}
+	}
+
+	// Results of older filters are ignored
+	p := New(path, Options{Text: "plain", Lines: 2, Page: 1, NoColor: true})
+	all := p.collectStats()
+	n := &navigator{p: p, all: all, fs: visibleStats(all), id: 1, page: 3, seq: 2}
+	np, err := p.withFilter("ERROR", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := np.rematch(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.applyFilter(filterResult{p: np, all: fs, seq: 1}); err != nil || n.p != p || n.page != 3 {
+		t.Errorf("an old filter result was applied: error %v, page %d", err, n.page)
+	}
+}
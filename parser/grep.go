@@ -0,0 +1,200 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Grep compatible output modes
+const (
+	grepOff = iota
+	// path:line:content
+	grepLines
+	// path:line:col:match
+	grepOnlyMatching
+	// path:count
+	grepCount
+	// Paths of the files with at least 1 matching line
+	grepFilesWithMatches
+	// Paths of the files without any matching line
+	grepFilesWithoutMatch
+)
+
+// Exit statuses mirroring grep
+const (
+	// At least 1 line (or file) was selected
+	statusMatch = 0
+	// Nothing was selected
+	statusNoMatch = 1
+	// Something went wrong
+	statusError = 2
+)
+
+// grepMode determines the grep compatible output mode from the options
+func (opts Options) grepMode() int {
+	switch {
+	case opts.FilesWithMatches:
+		return grepFilesWithMatches
+	case opts.FilesWithoutMatch:
+		return grepFilesWithoutMatch
+	case opts.Count:
+		return grepCount
+	case opts.OnlyMatching:
+		return grepOnlyMatching
+	case opts.Grep:
+		return grepLines
+	}
+	return grepOff
+}
+
+// matchSpans returns the start and end byte positions of all filter matches in a line
+func (p *Parser) matchSpans(line []byte) [][]int {
+	if p.filter == "" || p.excluded(line) {
+		return nil
+	}
+	if p.regex != nil {
+		return p.regex.FindAllIndex(line, -1)
+	}
+	var spans [][]int
+	filter := []byte(p.filter)
+	for start := 0; ; {
+		i := bytes.Index(line[start:], filter)
+		if i < 0 {
+			break
+		}
+		spans = append(spans, []int{start + i, start + i + len(filter)})
+		start += i + len(filter)
+	}
+	return spans
+}
+
+// highlightSpans colors the given spans of a line
+func highlightSpans(line []byte, spans [][]int) string {
+	var b bytes.Buffer
+	last := 0
+	for _, span := range spans {
+		b.Write(line[last:span[0]])
+		b.WriteString(success(string(line[span[0]:span[1]])))
+		last = span[1]
+	}
+	b.Write(line[last:])
+	return b.String()
+}
+
+// runGrep prints the matching lines of all files just like grep does
+// It returns the exit status mirroring grep semantics
+func (p *Parser) runGrep() int {
+	// Without a filter there is nothing to look for
+	if p.filter == "" {
+		exitWithError("Error! A filter is required for grep compatible output")
+	}
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	status := statusNoMatch
+	for _, path := range p.getPaths() {
+		count := p.grepFile(path, w)
+		switch p.grep {
+		case grepCount:
+			fmt.Fprintf(w, "%s:%d\n", info(path), count)
+		case grepFilesWithMatches:
+			if count > 0 {
+				fmt.Fprintln(w, info(path))
+			}
+		case grepFilesWithoutMatch:
+			if count == 0 {
+				fmt.Fprintln(w, info(path))
+				status = statusMatch
+			}
+		}
+		if count > 0 && p.grep != grepFilesWithoutMatch {
+			status = statusMatch
+		}
+	}
+	return status
+}
+
+// grepFile prints the matching lines of a file according to the grep mode
+// Matching lines come with their context lines when asked, just like grep -C
+// It returns the number of matching lines
+func (p *Parser) grepFile(path string, w io.Writer) int {
+	// Open the file
+	f, err := os.Open(path)
+	if err != nil {
+		fatalf("Cannot open file path %s, Error: %v", path, err)
+	}
+	defer f.Close()
+	// Start a new reader
+	r := bufio.NewReader(f)
+	// The number of the current line
+	var current int
+	// The number of matching lines
+	var count int
+	// Lines seen before the next matching line
+	var before []exportLine
+	// Number of context lines still to print after a matching line
+	var after int
+	// Number of the last printed line
+	var last int
+	// printLine prints a line of the grep output
+	// Context lines use - as separator instead of :, just like grep
+	printLine := func(number int, sep, text string) {
+		// Separate groups of lines that are not next to each other
+		if p.context > 0 && last > 0 && number > last+1 {
+			fmt.Fprintln(w, "--")
+		}
+		fmt.Fprintf(w, "%s%s%s%s%s\n", info(path), sep, alert(number), sep, text)
+		last = number
+	}
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			current++
+			line = bytes.TrimRight(line, "\r\n")
+			if spans := p.matchSpans(line); len(spans) > 0 {
+				count++
+				switch p.grep {
+				case grepLines:
+					for _, l := range before {
+						printLine(l.number, "-", p.redact(string(l.text)))
+					}
+					before, after = nil, p.context
+					text := highlightSpans(line, spans)
+					// Matches are found again once sensitive values are hidden
+					if p.redactor != nil {
+						red := []byte(p.redact(string(line)))
+						text = highlightSpans(red, p.matchSpans(red))
+					}
+					printLine(current, ":", text)
+				case grepOnlyMatching:
+					for _, span := range spans {
+						fmt.Fprintf(w, "%s:%s:%s:%s\n", info(path), alert(current), alert(span[0]+1), success(p.redact(string(line[span[0]:span[1]]))))
+					}
+				case grepFilesWithMatches:
+					// The first match is enough to list the file
+					return count
+				}
+			} else if p.grep == grepLines && p.context > 0 && !p.excluded(line) {
+				// Excluded lines are not even used as context
+				if after > 0 {
+					after--
+					printLine(current, "-", p.redact(string(line)))
+				} else {
+					// Keep the line in case the next ones match
+					before = append(before, exportLine{number: current, text: line})
+					if len(before) > p.context {
+						before = before[1:]
+					}
+				}
+			}
+		}
+		if err == io.EOF {
+			return count
+		}
+		if err != nil {
+			fatal("Grep lines error:", err)
+		}
+	}
+}
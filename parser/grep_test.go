@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestGrepFile tests the grep compatible lines with context and exclusions
+func TestGrepFile(t *testing.T) {
+	path, cleanup := writeTestFile(t, "a\nb\nERROR 1\nc\nd\ne\nf\nERROR 2\ng\nERROR 3\nh\ni\n")
+	defer cleanup()
+
+	tests := []struct {
+		name    string
+		context int
+		exclude string
+		count   int
+		want    []string
+	}{
+		{
+			"no context",
+			0, "", 3,
+			[]string{path + ":3:ERROR 1", path + ":8:ERROR 2", path + ":10:ERROR 3"},
+		},
+		{
+			// The windows of the last 2 matches overlap and are printed once
+			"context",
+			1, "", 3,
+			[]string{
+				path + "-2-b", path + ":3:ERROR 1", path + "-4-c",
+				"--",
+				path + "-7-f", path + ":8:ERROR 2", path + "-9-g", path + ":10:ERROR 3", path + "-11-h",
+			},
+		},
+		{
+			// Windows next to each other are not separated
+			"adjacent windows",
+			2, "", 3,
+			[]string{
+				path + "-1-a", path + "-2-b", path + ":3:ERROR 1", path + "-4-c", path + "-5-d",
+				path + "-6-e", path + "-7-f", path + ":8:ERROR 2", path + "-9-g", path + ":10:ERROR 3", path + "-11-h", path + "-12-i",
+			},
+		},
+		{
+			// Excluded lines are neither matches nor context
+			// The context goes on with the next line that is not excluded
+			"exclusions",
+			1, "2|c|f", 2,
+			[]string{
+				path + "-2-b", path + ":3:ERROR 1",
+				"--",
+				path + "-5-d",
+				"--",
+				path + "-9-g", path + ":10:ERROR 3", path + "-11-h",
+			},
+		},
+	}
+	for _, tt := range tests {
+		p := New(path, Options{Text: "plain", Filter: "ERROR", Exclude: tt.exclude, WithRegex: true, Grep: true, Context: tt.context, NoColor: true, Lines: 10, Page: 1})
+		var b bytes.Buffer
+		count := p.grepFile(path, &b)
+		got := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+		if count != tt.count {
+			t.Errorf("%s: got %d matches, want %d", tt.name, count, tt.count)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFiles writes temporary files with the given names and contents in a new folder
+// It returns the folder and a function removing it
+func writeTestFiles(t *testing.T, files map[string]string) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "logy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// writeTestFile writes a temporary log file with the given content
+// It returns the path of the file and a function removing it
+func writeTestFile(t *testing.T, content string) (string, func()) {
+	t.Helper()
+	dir, cleanup := writeTestFiles(t, map[string]string{"app.log": content})
+	return filepath.Join(dir, "app.log"), cleanup
+}
+
+// writeTestLog writes a temporary log file with the given number of lines
+// Every line is INFO line N unless level gives another level for it
+// It returns the path of the file and a function removing it
+func writeTestLog(t *testing.T, n int, level func(i int) string) (string, func()) {
+	t.Helper()
+	var lines []string
+	for i := 1; i <= n; i++ {
+		l := "INFO"
+		if level != nil {
+			l = level(i)
+		}
+		lines = append(lines, fmt.Sprintf("%s line %d", l, i))
+	}
+	return writeTestFile(t, strings.Join(lines, "\n")+"\n")
+}
@@ -0,0 +1,269 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bucket sizes chosen automatically for histograms
+var bucketSizes = []time.Duration{
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+}
+
+// Maximum number of buckets when the bucket size is chosen automatically
+const maxBuckets = 60
+
+// Maximum number of buckets of the chart shown in the terminal
+// Every bucket is a row so the chart must fit on the screen
+const maxChartBuckets = 24
+
+// Width of the longest bar of the histogram chart
+const barWidth = 40
+
+// Blocks used to draw the bars of the chart with a precision of 1/8 of a character
+var barBlocks = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉", "█"}
+
+// histogram counts events in time buckets of the same size
+type histogram struct {
+	// Start of the first bucket
+	start time.Time
+	// Size of every bucket
+	size time.Duration
+	// Number of events in every bucket
+	counts []int
+	// Number of events that had no timestamp
+	untimed int
+	// Size given by the user when it gave too many buckets and a larger one is used
+	requested time.Duration
+	// Maximum number of buckets
+	limit int
+}
+
+// timeCounter counts events per second
+// Seconds are enough for histograms and keep memory usage low for huge files
+type timeCounter struct {
+	seconds map[int64]int
+	untimed int
+}
+
+// newTimeCounter returns an empty counter
+func newTimeCounter() *timeCounter {
+	return &timeCounter{seconds: make(map[int64]int)}
+}
+
+// add counts an event for the timestamp found in the line (if any)
+func (tc *timeCounter) add(line string, n int) {
+	t, ok := parseTimestamp(line)
+	if !ok {
+		tc.untimed += n
+		return
+	}
+	tc.seconds[t.Unix()] += n
+}
+
+// histogram groups the counted events in buckets of the given size
+// A zero size means the size is chosen automatically to have at most limit buckets
+// A size giving more buckets is replaced by a multiple that does not,
+// preferably the automatic size
+// It returns nil when no event had a timestamp
+func (tc *timeCounter) histogram(size time.Duration, limit int) *histogram {
+	if len(tc.seconds) == 0 {
+		return nil
+	}
+	seconds := make([]int64, 0, len(tc.seconds))
+	for s := range tc.seconds {
+		seconds = append(seconds, s)
+	}
+	sort.Slice(seconds, func(i, j int) bool { return seconds[i] < seconds[j] })
+	first, last := seconds[0], seconds[len(seconds)-1]
+	span := time.Duration(last-first) * time.Second
+	count := func(size time.Duration) int {
+		return int(time.Unix(last, 0).Sub(localTruncate(time.Unix(first, 0), size))/size) + 1
+	}
+	// The automatic size is the smallest one that fits
+	auto := bucketSizes[len(bucketSizes)-1]
+	for _, s := range bucketSizes {
+		if count(s) <= limit {
+			auto = s
+			break
+		}
+	}
+	var requested time.Duration
+	switch {
+	case size <= 0:
+		size = auto
+	case count(size) > limit:
+		requested = size
+		// The automatic size is used when it is a multiple of the requested one
+		// otherwise the smallest multiple that fits
+		if auto%size == 0 {
+			size = auto
+			break
+		}
+		n := span/(size*time.Duration(limit)) + 1
+		for count(size*n) > limit {
+			n++
+		}
+		size *= n
+	}
+	start := localTruncate(time.Unix(first, 0), size)
+	h := &histogram{start: start, size: size, counts: make([]int, count(size)), untimed: tc.untimed, requested: requested, limit: limit}
+	for _, s := range seconds {
+		h.counts[int(time.Unix(s, 0).Sub(start)/size)] += tc.seconds[s]
+	}
+	return h
+}
+
+// localTruncate rounds a time down to a multiple of the size in the local time zone
+// so buckets start at the round times shown by their labels, e.g. at midnight for days
+func localTruncate(t time.Time, size time.Duration) time.Time {
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(size).Add(-shift)
+}
+
+// bucketStart returns the start time of a bucket
+func (h *histogram) bucketStart(i int) time.Time {
+	return h.start.Add(time.Duration(i) * h.size)
+}
+
+// notice tells that the bucket size given by the user was replaced
+func (h *histogram) notice() string {
+	return fmt.Sprintf("Bucket size %s gives more than %d buckets, %s is used instead", formatBucket(h.requested), h.limit, formatBucket(h.size))
+}
+
+// max returns the highest bucket count
+func (h *histogram) max() int {
+	var m int
+	for _, c := range h.counts {
+		if c > m {
+			m = c
+		}
+	}
+	return m
+}
+
+// parseBucket parses a bucket size like 30s, 5m or 1h
+// Days (1d) and weeks (1w) are accepted too. An empty size means automatic
+func parseBucket(s string) (time.Duration, error) {
+	invalid := errors.New("Error! Option flag -bucket must be a duration like 30s, 5m, 1h or 1d")
+	if s == "" {
+		return 0, nil
+	}
+	var size time.Duration
+	var err error
+	switch {
+	case strings.HasSuffix(s, "d"), strings.HasSuffix(s, "w"):
+		n, e := strconv.Atoi(s[:len(s)-1])
+		size, err = time.Duration(n)*24*time.Hour, e
+		if strings.HasSuffix(s, "w") {
+			size *= 7
+		}
+	default:
+		size, err = time.ParseDuration(s)
+	}
+	if err != nil || size < time.Second {
+		return 0, invalid
+	}
+	return size, nil
+}
+
+// formatBucket formats a bucket size the way it is typed by the user
+func formatBucket(size time.Duration) string {
+	day := 24 * time.Hour
+	if size%day == 0 {
+		return fmt.Sprintf("%dd", size/day)
+	}
+	s := size.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// bucketLayout returns the time layout showing enough detail for the bucket size
+func bucketLayout(size time.Duration) string {
+	switch {
+	case size < time.Minute:
+		return "2006-01-02 15:04:05"
+	case size < 24*time.Hour:
+		return "2006-01-02 15:04"
+	}
+	return "2006-01-02"
+}
+
+// matchHistogram counts the matches of all files in time buckets
+// Without a filter every displayed line counts as a match
+// It returns nil when no matching line has a timestamp
+func (p *Parser) matchHistogram(fs []stats) *histogram {
+	counter := newTimeCounter()
+	for _, stat := range fs {
+		err := p.eachRecord(stat, func(r record) bool {
+			n := len(r.Matches)
+			if n == 0 {
+				n = 1
+			}
+			counter.add(r.Text, n)
+			return true
+		})
+		if err != nil {
+			fatal(err)
+		}
+	}
+	return counter.histogram(p.bucket, maxChartBuckets)
+}
+
+// renderHistogram draws the histogram as a bar chart with a row for every bucket
+func renderHistogram(w io.Writer, h *histogram) {
+	if h == nil {
+		fmt.Fprintln(w, info("No timestamps were found in the matching lines"))
+		return
+	}
+	if h.requested > 0 {
+		fmt.Fprintln(w, alert(h.notice()))
+	}
+	layout := bucketLayout(h.size)
+	max := h.max()
+	// The peak is where a storm is easy to spot
+	peak := 0
+	for i, c := range h.counts {
+		if c > h.counts[peak] {
+			peak = i
+		}
+	}
+	fmt.Fprintln(w, info(fmt.Sprintf("Matches per %s (peak of %d at %s)", formatBucket(h.size), max, h.bucketStart(peak).Format(layout))))
+	for i, c := range h.counts {
+		// Bars are measured in 1/8 of a character
+		eighths := c * barWidth * 8 / max
+		if c > 0 && eighths == 0 {
+			eighths = 1
+		}
+		bar := strings.Repeat(barBlocks[8], eighths/8) + barBlocks[eighths%8]
+		fmt.Fprintf(w, "%s │%s %d\n", h.bucketStart(i).Format(layout), success(bar), c)
+	}
+	if h.untimed > 0 {
+		fmt.Fprintln(w, info(fmt.Sprintf("%d matches without a timestamp are not shown", h.untimed)))
+	}
+}
@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseBucket tests if bucket sizes are parsed like the user types them
+func TestParseBucket(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{"", 0, false},
+		{"30s", 30 * time.Second, false},
+		{"5m", 5 * time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"2d", 48 * time.Hour, false},
+		{"1w", 7 * 24 * time.Hour, false},
+		{"500ms", 0, true},
+		{"-1m", 0, true},
+		{"xd", 0, true},
+		{"1x", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseBucket(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseBucket(%q) = %v, %v, want %v, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+// TestHistogram tests if events are counted in the right buckets
+func TestHistogram(t *testing.T) {
+	// Buckets are aligned in local time
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = time.UTC
+	tc := newTimeCounter()
+	tc.add("2019-12-10T15:00:05Z ERROR first", 1)
+	tc.add("2019-12-10T15:00:50Z ERROR second", 2)
+	tc.add("2019-12-10T15:03:10Z ERROR third", 1)
+	tc.add("no timestamp", 3)
+
+	h := tc.histogram(time.Minute, maxChartBuckets)
+	want := []int{3, 0, 0, 1}
+	if len(h.counts) != len(want) {
+		t.Fatalf("got %d buckets, want %d", len(h.counts), len(want))
+	}
+	for i := range want {
+		if h.counts[i] != want[i] {
+			t.Errorf("bucket %d: got %d, want %d", i, h.counts[i], want[i])
+		}
+	}
+	if h.untimed != 3 {
+		t.Errorf("got %d untimed events, want 3", h.untimed)
+	}
+	if start := h.bucketStart(3).UTC(); !start.Equal(time.Date(2019, 12, 10, 15, 3, 0, 0, time.UTC)) {
+		t.Errorf("got bucket start %v", start)
+	}
+	// The automatic size keeps the number of buckets under the limit
+	if h := tc.histogram(0, 3); h.size != 5*time.Minute || len(h.counts) != 1 {
+		t.Errorf("got automatic size %v with %d buckets", h.size, len(h.counts))
+	}
+	// A size giving too many buckets is replaced by a multiple that does not
+	long := newTimeCounter()
+	long.add("2019-12-10T00:00:00Z ERROR first", 1)
+	long.add("2019-12-12T00:00:00Z ERROR last", 1)
+	tests := []struct {
+		size      time.Duration
+		limit     int
+		want      time.Duration
+		requested time.Duration
+		notice    string
+	}{
+		// The automatic size is a multiple of the requested one
+		{time.Second, maxChartBuckets, 3 * time.Hour, time.Second, "Bucket size 1s gives more than 24 buckets, 3h is used instead"},
+		// Otherwise the smallest multiple that fits
+		{7 * time.Second, maxChartBuckets, 7210 * time.Second, 7 * time.Second, "Bucket size 7s gives more than 24 buckets, 2h0m10s is used instead"},
+		{time.Minute, maxBuckets, time.Hour, time.Minute, "Bucket size 1m gives more than 60 buckets, 1h is used instead"},
+		{3 * time.Hour, maxChartBuckets, 3 * time.Hour, 0, ""},
+	}
+	for _, tt := range tests {
+		h := long.histogram(tt.size, tt.limit)
+		if h.size != tt.want || h.requested != tt.requested || len(h.counts) > tt.limit {
+			t.Errorf("histogram(%v, %d): got size %v requested %v with %d buckets", tt.size, tt.limit, h.size, h.requested, len(h.counts))
+		} else if h.requested > 0 && h.notice() != tt.notice {
+			t.Errorf("histogram(%v, %d): got notice %q, want %q", tt.size, tt.limit, h.notice(), tt.notice)
+		}
+	}
+	if h := newTimeCounter().histogram(0, maxBuckets); h != nil {
+		t.Errorf("got a histogram without timestamps")
+	}
+}
+
+// TestHistogramLocalTime tests if the buckets start at round times of the local time zone
+func TestHistogramLocalTime(t *testing.T) {
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = time.FixedZone("UTC+3", 3*60*60)
+	tc := newTimeCounter()
+	// Both events happen on December 11 in local time
+	tc.add("2019-12-10T22:00:00Z ERROR first", 1)
+	tc.add("2019-12-11T20:00:00Z ERROR last", 1)
+	h := tc.histogram(24*time.Hour, maxChartBuckets)
+	if len(h.counts) != 1 || h.counts[0] != 2 {
+		t.Fatalf("got counts %v, want a single bucket", h.counts)
+	}
+	if got := h.bucketStart(0).Format(bucketLayout(h.size) + " 15:04"); got != "2019-12-11 00:00" {
+		t.Errorf("got bucket start %s, want 2019-12-11 00:00", got)
+	}
+}
@@ -0,0 +1,275 @@
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Shown instead of the members of collapsed objects and arrays
+const collapsedMark = "..."
+
+// jsonNode is a parsed JSON value
+// Keys and values keep their original text so nothing changes but the spaces
+type jsonNode struct {
+	// '{' for objects, '[' for arrays and 0 for the other values
+	kind byte
+	// Text of strings, numbers, booleans and null
+	text string
+	// Keys of the object members, with their quotes
+	keys []string
+	// Values of the object members or items of the array
+	items []*jsonNode
+}
+
+// parseJSON parses a JSON text
+// The second value is false when the JSON is malformed
+func parseJSON(text string) (*jsonNode, bool) {
+	if !json.Valid([]byte(text)) {
+		return nil, false
+	}
+	n, _ := readJSON(text, 0)
+	return n, true
+}
+
+// readJSON reads the value starting at i of a valid JSON text
+// It returns the position after the value
+func readJSON(text string, i int) (*jsonNode, int) {
+	i = skipSpaces(text, i)
+	switch text[i] {
+	case '{', '[':
+		n := &jsonNode{kind: text[i]}
+		closing := closingOf(n.kind)
+		for i = skipSpaces(text, i+1); text[i] != closing[0]; {
+			if n.kind == '{' {
+				end := stringEnd(text, i)
+				n.keys = append(n.keys, text[i:end])
+				// Skip the colon
+				i = skipSpaces(text, end) + 1
+			}
+			var item *jsonNode
+			item, i = readJSON(text, i)
+			n.items = append(n.items, item)
+			if i = skipSpaces(text, i); text[i] == ',' {
+				i = skipSpaces(text, i+1)
+			}
+		}
+		return n, i + 1
+	case '"':
+		end := stringEnd(text, i)
+		return &jsonNode{text: text[i:end]}, end
+	}
+	end := i
+	for end < len(text) && strings.IndexByte(",}] \t\r\n", text[end]) < 0 {
+		end++
+	}
+	return &jsonNode{text: text[i:end]}, end
+}
+
+// skipSpaces returns the position of the first character after the spaces
+func skipSpaces(text string, i int) int {
+	for i < len(text) && strings.IndexByte(" \t\r\n", text[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// stringEnd returns the position after the JSON string starting at i
+func stringEnd(text string, i int) int {
+	for i++; i < len(text) && text[i] != '"'; i++ {
+		if text[i] == '\\' {
+			i++
+		}
+	}
+	return i + 1
+}
+
+// closingOf returns the character closing an object or an array
+func closingOf(kind byte) string {
+	if kind == '[' {
+		return "]"
+	}
+	return "}"
+}
+
+// findJSON finds the JSON objects and arrays embedded in a text
+// Braces inside strings are skipped and every candidate must be valid JSON
+// Arrays must hold objects, arrays or strings so things like [123] or [main] are not taken for JSON
+// It returns the start and end of every JSON text
+func findJSON(text string) [][]int {
+	var found [][]int
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c != '{' && c != '[' {
+			continue
+		}
+		if c == '[' {
+			if j := skipSpaces(text, i+1); j >= len(text) || strings.IndexByte("{[\"", text[j]) < 0 {
+				continue
+			}
+		}
+		end := balancedEnd(text, i)
+		if end < 0 || !json.Valid([]byte(text[i:end])) {
+			continue
+		}
+		found = append(found, []int{i, end})
+		i = end - 1
+	}
+	return found
+}
+
+// balancedEnd returns the position after the brace or bracket closing the one at i
+// It returns -1 when it is never closed or closed by the wrong character
+func balancedEnd(text string, i int) int {
+	var stack []byte
+	for ; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '{', '[':
+			stack = append(stack, closingOf(c)[0])
+		case '}', ']':
+			if stack[len(stack)-1] != c {
+				return -1
+			}
+			if stack = stack[:len(stack)-1]; len(stack) == 0 {
+				return i + 1
+			}
+		case '"':
+			i = stringEnd(text, i) - 1
+		}
+	}
+	return -1
+}
+
+// jsonFormatter formats the JSON found in the lines
+type jsonFormatter struct {
+	// Objects and arrays nested deeper are collapsed (0 means they never are)
+	depth int
+	// Print every JSON on a single line
+	compact bool
+	// Fields always printed in full and indented
+	// Nested fields are separated by dots, e.g. request.headers
+	expand []string
+}
+
+// format formats a JSON text
+// Malformed JSON is returned as it is and the second value is false
+func (f *jsonFormatter) format(text string) (string, bool) {
+	n, ok := parseJSON(text)
+	if !ok {
+		return text, false
+	}
+	var b strings.Builder
+	f.write(&b, n, "", "", 1, f.compact, false)
+	return b.String(), true
+}
+
+// write writes a JSON value
+// The path is the dotted name of the field holding the value and level its nesting level
+// Full values are never collapsed
+func (f *jsonFormatter) write(b *strings.Builder, n *jsonNode, indent, path string, level int, compact, full bool) {
+	if n.kind == 0 {
+		b.WriteString(n.text)
+		return
+	}
+	closing := closingOf(n.kind)
+	b.WriteByte(n.kind)
+	if len(n.items) == 0 {
+		b.WriteString(closing)
+		return
+	}
+	if !full && f.depth > 0 && level > f.depth {
+		b.WriteString(collapsedMark + closing)
+		return
+	}
+	// Expanded fields of a compact line start at the beginning of the line
+	inner := indent + "  "
+	if compact {
+		inner = ""
+	}
+	for i, item := range n.items {
+		if i > 0 {
+			b.WriteByte(',')
+			if compact {
+				b.WriteByte(' ')
+			}
+		}
+		if !compact {
+			b.WriteString("\n" + inner)
+		}
+		itemPath, itemCompact, itemFull := path, compact, full
+		if n.kind == '{' {
+			b.WriteString(n.keys[i] + ": ")
+			itemPath = jsonKey(n.keys[i])
+			if path != "" {
+				itemPath = path + "." + itemPath
+			}
+			if stringInSlice(itemPath, f.expand) {
+				itemCompact, itemFull = false, true
+			}
+		}
+		f.write(b, item, inner, itemPath, level+1, itemCompact, itemFull)
+	}
+	if !compact {
+		b.WriteString("\n" + indent)
+	}
+	b.WriteString(closing)
+}
+
+// jsonKey returns the name of a quoted key
+func jsonKey(quoted string) string {
+	var key string
+	if err := json.Unmarshal([]byte(quoted), &key); err != nil {
+		return strings.Trim(quoted, `"`)
+	}
+	return key
+}
+
+// jsonSpans finds the keys and values of a formatted JSON text
+// Offset is the position of the JSON text in the line
+func jsonSpans(text string, offset int) []colorSpan {
+	var spans []colorSpan
+	add := func(start, end int, key string) {
+		spans = append(spans, colorSpan{offset + start, offset + end, currentTheme.get(key)})
+	}
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '"':
+			// Strings end at the next quote that is not escaped
+			end := i + 1
+			for end < len(text) && text[end] != '"' {
+				if text[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++
+			if end > len(text) {
+				end = len(text)
+			}
+			// Keys are the strings followed by a colon
+			key := "json.string"
+			if rest := strings.TrimLeft(text[end:], " \n\t"); strings.HasPrefix(rest, ":") {
+				key = "json.key"
+			}
+			add(i, end, key)
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(text) && strings.IndexByte("0123456789.eE+-", text[end]) >= 0 {
+				end++
+			}
+			add(i, end, "json.number")
+			i = end
+		case strings.HasPrefix(text[i:], "true"), strings.HasPrefix(text[i:], "false"):
+			end := i + strings.IndexByte(text[i:], 'e') + 1
+			add(i, end, "json.bool")
+			i = end
+		case strings.HasPrefix(text[i:], "null"):
+			add(i, i+4, "json.null")
+			i += 4
+		default:
+			i++
+		}
+	}
+	return spans
+}
@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestJSONFormatter tests formatting, collapsing and compacting JSON
+func TestJSONFormatter(t *testing.T) {
+	text := `{"a":1,"b":{"c":"x\"}","d":[true,null]},"e":[],"f":{}}`
+	tests := []struct {
+		name string
+		f    jsonFormatter
+		text string
+		want string
+		ok   bool
+	}{
+		{"indent", jsonFormatter{}, text, "{\n  \"a\": 1,\n  \"b\": {\n    \"c\": \"x\\\"}\",\n    \"d\": [\n      true,\n      null\n    ]\n  },\n  \"e\": [],\n  \"f\": {}\n}", true},
+		{"depth 1", jsonFormatter{depth: 1}, text, "{\n  \"a\": 1,\n  \"b\": {...},\n  \"e\": [],\n  \"f\": {}\n}", true},
+		{"depth 2", jsonFormatter{depth: 2}, text, "{\n  \"a\": 1,\n  \"b\": {\n    \"c\": \"x\\\"}\",\n    \"d\": [...]\n  },\n  \"e\": [],\n  \"f\": {}\n}", true},
+		{"compact", jsonFormatter{compact: true}, text, `{"a": 1, "b": {"c": "x\"}", "d": [true, null]}, "e": [], "f": {}}`, true},
+		{"compact depth", jsonFormatter{compact: true, depth: 1}, text, `{"a": 1, "b": {...}, "e": [], "f": {}}`, true},
+		{"expand", jsonFormatter{compact: true, depth: 1, expand: []string{"b.d"}}, text, "{\"a\": 1, \"b\": {...}, \"e\": [], \"f\": {}}", true},
+		{"expand nested", jsonFormatter{compact: true, expand: []string{"b.d"}}, text, "{\"a\": 1, \"b\": {\"c\": \"x\\\"}\", \"d\": [\n  true,\n  null\n]}, \"e\": [], \"f\": {}}", true},
+		{"expand collapsed", jsonFormatter{compact: true, depth: 1, expand: []string{"b"}}, text, "{\"a\": 1, \"b\": {\n  \"c\": \"x\\\"}\",\n  \"d\": [\n    true,\n    null\n  ]\n}, \"e\": [], \"f\": {}}", true},
+		{"array", jsonFormatter{}, `[ 1 , "a" ]`, "[\n  1,\n  \"a\"\n]", true},
+		{"malformed", jsonFormatter{}, `{"a": 1,}`, `{"a": 1,}`, false},
+		{"truncated", jsonFormatter{}, `{"a": "b`, `{"a": "b`, false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.f.format(tt.text)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: format() = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestFindJSON tests finding the JSON embedded in lines
+func TestFindJSON(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{`no json here`, nil},
+		{`req {"a":1} took 5ms {"b":2}`, []string{`{"a":1}`, `{"b":2}`}},
+		{`{"a":"}{"} tail }`, []string{`{"a":"}{"}`}},
+		{`{"a":"x\"}"}`, []string{`{"a":"x\"}"}`}},
+		{`{"a":1 {"b":[1,2]}`, []string{`{"b":[1,2]}`}},
+		{`[main] pid [123] list [{"a":1},{"b":2}] ["x"]`, []string{`[{"a":1},{"b":2}]`, `["x"]`}},
+		{`{"a":[1}]`, nil},
+		{`{bad} {"ok":true}`, []string{`{"ok":true}`}},
+		{`{"a":{"b":`, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, m := range findJSON(tt.text) {
+			got = append(got, tt.text[m[0]:m[1]])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findJSON(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestJSONSpans tests finding the keys and values of JSON texts
+func TestJSONSpans(t *testing.T) {
+	text := `{"a": "x\"y", "n": -1.5e3, "ok": true, "no": false, "v": null}`
+	want := []struct {
+		part string
+		key  string
+	}{
+		{`"a"`, "json.key"},
+		{`"x\"y"`, "json.string"},
+		{`"n"`, "json.key"},
+		{`-1.5e3`, "json.number"},
+		{`"ok"`, "json.key"},
+		{`true`, "json.bool"},
+		{`"no"`, "json.key"},
+		{`false`, "json.bool"},
+		{`"v"`, "json.key"},
+		{`null`, "json.null"},
+	}
+	spans := jsonSpans(text, 10)
+	if len(spans) != len(want) {
+		t.Fatalf("jsonSpans() found %d spans, want %d", len(spans), len(want))
+	}
+	for i, s := range spans {
+		if got := text[s.start-10 : s.end-10]; got != want[i].part {
+			t.Errorf("span %d = %q, want %q", i, got, want[i].part)
+		}
+		if s.style.sequence(colorsTrue) != currentTheme.get(want[i].key).sequence(colorsTrue) {
+			t.Errorf("span %d style is not the one of %s", i, want[i].key)
+		}
+	}
+}
+
+// TestJSONExpandOption tests if the fields of --json-expand are trimmed and empty ones are dropped
+func TestJSONExpandOption(t *testing.T) {
+	path, cleanup := writeTestFile(t, `{"request": {"id": 1}}`+"\n")
+	defer cleanup()
+	tests := []struct {
+		expand string
+		want   []string
+	}{
+		{"", nil},
+		{"request,response.body", []string{"request", "response.body"}},
+		{" request , ,response.body,", []string{"request", "response.body"}},
+		{" , ", nil},
+	}
+	for _, tt := range tests {
+		p := New(path, Options{Text: "json", Lines: 10, Page: 1, NoColor: true, JSONExpand: tt.expand})
+		if !reflect.DeepEqual(p.jsonFmt.expand, tt.want) {
+			t.Errorf("--json-expand=%q gives fields %q, want %q", tt.expand, p.jsonFmt.expand, tt.want)
+		}
+	}
+}
@@ -0,0 +1,468 @@
+package parser
+
+import (
+	"encoding/xml"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Tags, comments and processing instructions of XML texts
+var xmlTagReg = regexp.MustCompile(`<!--.*?-->|<[?!][^>]*>|</?[A-Za-z_][\w:.-]*(?:\s+[\w:.-]+\s*=\s*(?:"[^"]*"|'[^']*'))*\s*/?>`)
+
+// Attributes inside XML tags
+var xmlAttrReg = regexp.MustCompile(`([\w:.-]+)\s*=\s*("[^"]*"|'[^']*')`)
+
+// xmlNode is a node of a parsed XML document
+type xmlNode struct {
+	// Start tag of elements, text of the other nodes
+	text string
+	// End tag of elements, empty for the other nodes
+	end      string
+	children []*xmlNode
+}
+
+// findXML finds the XML documents embedded in a text
+// A document is a root element closed on the same line, optionally after a declaration
+// Unclosed or mismatched tags are not documents
+// It returns the start and end of every document
+func findXML(text string) [][]int {
+	var found [][]int
+	for i := 0; i < len(text); i++ {
+		if text[i] != '<' || i+1 == len(text) {
+			continue
+		}
+		if c := text[i+1]; c != '?' && c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') {
+			continue
+		}
+		if _, end, ok := parseXML(text[i:]); ok {
+			found = append(found, []int{i, i + end})
+			i += end - 1
+		}
+	}
+	return found
+}
+
+// parseXML parses the XML document at the start of a text
+// It returns the top level nodes and the length of the document
+// The second value is false when there is no complete document
+func parseXML(text string) ([]*xmlNode, int, bool) {
+	d := xml.NewDecoder(strings.NewReader(text))
+	var (
+		top   []*xmlNode
+		stack []*xmlNode
+		names []string
+	)
+	add := func(n *xmlNode) {
+		if len(stack) == 0 {
+			top = append(top, n)
+			return
+		}
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, n)
+	}
+	for {
+		// Raw tokens keep the namespace prefixes as they are written
+		tok, err := d.RawToken()
+		if err != nil {
+			// The text ended before the root element was closed
+			return nil, 0, false
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var b strings.Builder
+			b.WriteString("<" + xmlName(t.Name))
+			for _, a := range t.Attr {
+				b.WriteString(" " + xmlName(a.Name) + `="` + escapeXML(a.Value, true) + `"`)
+			}
+			b.WriteString(">")
+			n := &xmlNode{text: b.String(), end: "</" + xmlName(t.Name) + ">"}
+			add(n)
+			stack = append(stack, n)
+			names = append(names, xmlName(t.Name))
+		case xml.EndElement:
+			if len(names) == 0 || names[len(names)-1] != xmlName(t.Name) {
+				return nil, 0, false
+			}
+			stack, names = stack[:len(stack)-1], names[:len(names)-1]
+			if len(stack) == 0 {
+				return top, int(d.InputOffset()), true
+			}
+		case xml.CharData:
+			// Spaces between tags are replaced by the indentation
+			if s := strings.TrimSpace(string(t)); s != "" {
+				if len(stack) == 0 {
+					return nil, 0, false
+				}
+				add(&xmlNode{text: escapeXML(s, false)})
+			}
+		case xml.Comment:
+			add(&xmlNode{text: "<!--" + string(t) + "-->"})
+		case xml.ProcInst:
+			inst := strings.TrimSpace(string(t.Inst))
+			if inst != "" {
+				inst = " " + inst
+			}
+			add(&xmlNode{text: "<?" + t.Target + inst + "?>"})
+		case xml.Directive:
+			add(&xmlNode{text: "<!" + string(t) + ">"})
+		}
+	}
+}
+
+// xmlName returns a name with its namespace prefix
+func xmlName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// escapeXML escapes the special characters of a text or an attribute value
+func escapeXML(s string, attr bool) string {
+	s = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+	if attr {
+		s = strings.Replace(s, `"`, "&quot;", -1)
+	}
+	return s
+}
+
+// formatXML indents an XML document
+// Malformed XML is returned as it is and the second value is false
+func formatXML(text string) (string, bool) {
+	top, end, ok := parseXML(text)
+	if !ok || end != len(text) {
+		return text, false
+	}
+	var lines []string
+	for _, n := range top {
+		lines = append(lines, xmlLines(n)...)
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// xmlLines returns the indented lines of a node
+// Elements holding only text stay on one line
+func xmlLines(n *xmlNode) []string {
+	switch {
+	case n.end == "":
+		return []string{n.text}
+	case len(n.children) == 0:
+		return []string{strings.TrimSuffix(n.text, ">") + "/>"}
+	case len(n.children) == 1 && n.children[0].end == "" && !strings.HasPrefix(n.children[0].text, "<"):
+		return []string{n.text + n.children[0].text + n.end}
+	}
+	lines := []string{n.text}
+	for _, child := range n.children {
+		for _, line := range xmlLines(child) {
+			lines = append(lines, "  "+line)
+		}
+	}
+	return append(lines, n.end)
+}
+
+// xmlSpans finds the tags, attributes and comments of an XML text
+// Tags are colored even when the document is malformed or spread over many lines
+func xmlSpans(text string) []colorSpan {
+	var spans []colorSpan
+	for _, m := range xmlTagReg.FindAllStringIndex(text, -1) {
+		tag := text[m[0]:m[1]]
+		if strings.HasPrefix(tag, "<!") || strings.HasPrefix(tag, "<?") {
+			spans = append(spans, colorSpan{m[0], m[1], currentTheme.get("comment")})
+			continue
+		}
+		spans = append(spans, colorSpan{m[0], m[1], currentTheme.get("xml.tag")})
+		for _, a := range xmlAttrReg.FindAllStringSubmatchIndex(tag, -1) {
+			spans = append(spans,
+				colorSpan{m[0] + a[2], m[0] + a[3], currentTheme.get("xml.attr")},
+				colorSpan{m[0] + a[4], m[0] + a[5], currentTheme.get("json.string")},
+			)
+		}
+	}
+	return spans
+}
+
+// Scalars of YAML texts
+var (
+	yamlNullReg   = regexp.MustCompile(`^(?:~|null|Null|NULL)$`)
+	yamlBoolReg   = regexp.MustCompile(`^(?:true|True|TRUE|false|False|FALSE|yes|Yes|YES|no|No|NO|on|On|ON|off|Off|OFF)$`)
+	yamlNumberReg = regexp.MustCompile(`^[-+]?(?:\d[\d_]*(?:\.\d*)?(?:[eE][-+]?\d+)?|\.\d+|0x[0-9a-fA-F]+|\.inf|\.Inf|\.nan|\.NaN)$`)
+	// Keys are followed by a colon and a space or the end of the line
+	yamlKeyReg = regexp.MustCompile(`^("(?:[^"\\]|\\.)*"|'[^']*'|[^\s"'#{\[][^#{}\[\]]*?):(?:\s|$)`)
+	// Outside of the detected documents plain keys cannot have spaces
+	// so log lines like 2020-01-01 12:00:00 ERROR: failed are not taken for YAML
+	yamlLineKeyReg = regexp.MustCompile(`^("(?:[^"\\]|\\.)*"|'[^']*'|[^\s"'#{\[][^\s#{}\[\]]*?):(?:\s|$)`)
+)
+
+// findYAML finds the YAML flow mappings embedded in a text, e.g. {name: web, ports: [80, 443]}
+// It returns the start and end of every mapping
+func findYAML(text string) [][]int {
+	var found [][]int
+	for i := 0; i < len(text); i++ {
+		if text[i] != '{' {
+			continue
+		}
+		end := balancedEnd(text, i)
+		if end < 0 || !strings.Contains(text[i:end], ":") {
+			continue
+		}
+		if _, ok := parseFlowYAML(text[i:end]); !ok {
+			continue
+		}
+		found = append(found, []int{i, end})
+		i = end - 1
+	}
+	return found
+}
+
+// parseFlowYAML parses a YAML flow mapping
+// The second value is false when the YAML is malformed or the mapping is empty
+func parseFlowYAML(text string) (*jsonNode, bool) {
+	var value map[interface{}]interface{}
+	if err := yaml.Unmarshal([]byte(text), &value); err != nil || len(value) == 0 {
+		return nil, false
+	}
+	n, end, ok := readFlowYAML(text, 0)
+	if !ok || skipSpaces(text, end) != len(text) {
+		return nil, false
+	}
+	return n, true
+}
+
+// readFlowYAML reads the flow value starting at i
+// Scalars keep their text so values like no or 010 are not changed
+// It returns the position after the value
+func readFlowYAML(text string, i int) (*jsonNode, int, bool) {
+	i = skipSpaces(text, i)
+	if i >= len(text) {
+		return nil, i, false
+	}
+	switch c := text[i]; c {
+	case '{', '[':
+		n := &jsonNode{kind: c}
+		closing := closingOf(c)[0]
+		for i = skipSpaces(text, i+1); i < len(text) && text[i] != closing; {
+			if c == '{' {
+				key, end := readYAMLScalar(text, i, true)
+				n.keys = append(n.keys, key)
+				i = skipSpaces(text, end)
+				// Keys without a value are null
+				if i < len(text) && text[i] != ':' {
+					n.items = append(n.items, &jsonNode{})
+				} else {
+					var item *jsonNode
+					var ok bool
+					if item, i, ok = readFlowYAML(text, i+1); !ok {
+						return nil, i, false
+					}
+					n.items = append(n.items, item)
+				}
+			} else {
+				item, end, ok := readFlowYAML(text, i)
+				if !ok {
+					return nil, i, false
+				}
+				n.items = append(n.items, item)
+				i = end
+			}
+			// Items are followed by a comma or the end of the collection
+			if i = skipSpaces(text, i); i < len(text) && text[i] == ',' {
+				i = skipSpaces(text, i+1)
+			} else if i >= len(text) || text[i] != closing {
+				return nil, i, false
+			}
+		}
+		if i >= len(text) {
+			return nil, i, false
+		}
+		return n, i + 1, true
+	}
+	scalar, end := readYAMLScalar(text, i, false)
+	return &jsonNode{text: scalar}, end, true
+}
+
+// readYAMLScalar reads a quoted or plain scalar of a flow collection
+// Plain keys end at a colon followed by a space or another flow indicator
+func readYAMLScalar(text string, i int, key bool) (string, int) {
+	start := i
+	switch text[i] {
+	case '"':
+		end := stringEnd(text, i)
+		return text[start:end], end
+	case '\'':
+		// Single quotes are escaped by doubling them
+		for i++; i < len(text); i++ {
+			if text[i] == '\'' {
+				if i+1 < len(text) && text[i+1] == '\'' {
+					i++
+					continue
+				}
+				return text[start : i+1], i + 1
+			}
+		}
+		return text[start:], len(text)
+	}
+	for ; i < len(text); i++ {
+		c := text[i]
+		if c == ',' || c == '}' || c == ']' {
+			break
+		}
+		if c == ':' && (i+1 == len(text) || strings.IndexByte(" ,}]", text[i+1]) >= 0 || key) {
+			break
+		}
+	}
+	return strings.TrimSpace(text[start:i]), i
+}
+
+// formatYAML turns a YAML flow mapping into block style
+// Malformed YAML is returned as it is and the second value is false
+func formatYAML(text string) (string, bool) {
+	n, ok := parseFlowYAML(text)
+	if !ok {
+		return text, false
+	}
+	return strings.Join(yamlLines(n), "\n"), true
+}
+
+// yamlLines returns the block style lines of a flow value
+func yamlLines(n *jsonNode) []string {
+	var lines []string
+	for i, item := range n.items {
+		prefix := "- "
+		if n.kind == '{' {
+			prefix = n.keys[i] + ": "
+		}
+		if item.kind == 0 || len(item.items) == 0 {
+			lines = append(lines, strings.TrimRight(prefix+yamlScalar(item), " "))
+			continue
+		}
+		sub := yamlLines(item)
+		if n.kind == '[' && item.kind == '{' {
+			// The first member of a mapping inside a list goes next to the dash
+			lines = append(lines, prefix+sub[0])
+			sub = sub[1:]
+		} else {
+			lines = append(lines, strings.TrimRight(prefix, " "))
+		}
+		for _, line := range sub {
+			lines = append(lines, "  "+line)
+		}
+	}
+	return lines
+}
+
+// yamlScalar returns the text of a scalar or an empty collection
+func yamlScalar(n *jsonNode) string {
+	if n.kind == 0 {
+		return n.text
+	}
+	return string(n.kind) + closingOf(n.kind)
+}
+
+// embedYAML replaces the YAML flow mappings of a line by their block style
+// Lines of block YAML, e.g. multi document dumps, are already indented and left as they are
+// Blocks start on their own line and are indented when the line has other text
+// It also tells which lines of the result belong to a block
+func embedYAML(text string) (string, []bool) {
+	docs := findYAML(text)
+	if docs == nil {
+		return text, nil
+	}
+	type part struct {
+		text  string
+		block bool
+	}
+	var parts []part
+	var other bool
+	addText := func(s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			parts, other = append(parts, part{text: s}), true
+		}
+	}
+	last := 0
+	for _, m := range docs {
+		addText(text[last:m[0]])
+		block, _ := formatYAML(text[m[0]:m[1]])
+		parts = append(parts, part{text: block, block: true})
+		last = m[1]
+	}
+	addText(text[last:])
+	lines := make([]string, len(parts))
+	var inDoc []bool
+	for i, p := range parts {
+		lines[i] = p.text
+		if p.block && other {
+			lines[i] = "  " + strings.Replace(p.text, "\n", "\n  ", -1)
+		}
+		for n := strings.Count(lines[i], "\n"); n >= 0; n-- {
+			inDoc = append(inDoc, p.block)
+		}
+	}
+	return strings.Join(lines, "\n"), inDoc
+}
+
+// yamlSpans finds the keys, scalars and comments of every line of a YAML text
+// Lines are colored one by one. The ones outside of the detected documents (inDoc is false)
+// are only taken for YAML when their key has no spaces, since they are often regular log lines
+func yamlSpans(text string, inDoc []bool) []colorSpan {
+	var spans []colorSpan
+	offset := 0
+	for i, line := range strings.Split(text, "\n") {
+		keyReg := yamlLineKeyReg
+		if i < len(inDoc) && inDoc[i] {
+			keyReg = yamlKeyReg
+		}
+		spans = append(spans, yamlLineSpans(line, offset, keyReg)...)
+		offset += len(line) + 1
+	}
+	return spans
+}
+
+// yamlLineSpans finds the key, the scalar and the comment of a YAML line
+func yamlLineSpans(line string, offset int, keyReg *regexp.Regexp) []colorSpan {
+	var spans []colorSpan
+	add := func(start, end int, key string) {
+		spans = append(spans, colorSpan{offset + start, offset + end, currentTheme.get(key)})
+	}
+	// Skip the indentation and the dashes of list items
+	// Lines with neither a dash nor a key are regular text
+	i := skipSpaces(line, 0)
+	var item bool
+	for strings.HasPrefix(line[i:], "- ") || line[i:] == "-" {
+		i, item = skipSpaces(line, i+1), true
+	}
+	rest := line[i:]
+	if rest == "---" || rest == "..." || strings.HasPrefix(rest, "--- ") || strings.HasPrefix(rest, "#") {
+		add(i, len(line), "comment")
+		return spans
+	}
+	if m := keyReg.FindStringSubmatchIndex(rest); m != nil {
+		add(i+m[2], i+m[3], "json.key")
+		i, item = i+m[3]+1, true
+	}
+	if !item {
+		return spans
+	}
+	value := line[i:]
+	if c := strings.Index(value, " #"); c >= 0 {
+		add(i+c+1, len(line), "comment")
+		value = value[:c]
+	}
+	v := strings.TrimSpace(value)
+	if v == "" || strings.IndexByte("|>&*!{[", v[0]) >= 0 {
+		return spans
+	}
+	start := i + strings.Index(value, v)
+	switch {
+	case yamlNullReg.MatchString(v):
+		add(start, start+len(v), "json.null")
+	case yamlBoolReg.MatchString(v):
+		add(start, start+len(v), "json.bool")
+	case yamlNumberReg.MatchString(v):
+		add(start, start+len(v), "json.number")
+	default:
+		add(start, start+len(v), "json.string")
+	}
+	return spans
+}
@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestFormatXML tests indenting XML documents
+func TestFormatXML(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		ok   bool
+	}{
+		{`<a><b>x</b><c/></a>`, "<a>\n  <b>x</b>\n  <c/>\n</a>", true},
+		{`<?xml version="1.0"?><s:E xmlns:s="u"><s:B k='v &amp; w'><!-- c -->x &lt; y</s:B></s:E>`,
+			"<?xml version=\"1.0\"?>\n<s:E xmlns:s=\"u\">\n  <s:B k=\"v &amp; w\">\n    <!-- c -->\n    x &lt; y\n  </s:B>\n</s:E>", true},
+		{"<a>\n  <b> x </b>\n</a>", "<a>\n  <b>x</b>\n</a>", true},
+		{`<a><b></a>`, `<a><b></a>`, false},
+		{`<a>x`, `<a>x`, false},
+		{`<a>&nbsp;</a>`, `<a>&nbsp;</a>`, false},
+	}
+	for _, tt := range tests {
+		got, ok := formatXML(tt.text)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("formatXML(%q) = %q, %v, want %q, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestFindXML tests finding the XML documents embedded in lines
+func TestFindXML(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{`1 < 2 and <br> alone`, nil},
+		{`req <a>1</a> then <b/> done`, []string{`<a>1</a>`, `<b/>`}},
+		{`<a><b></a> <c>ok</c>`, []string{`<c>ok</c>`}},
+		{`<?xml version="1.0"?><a/> tail`, []string{`<?xml version="1.0"?><a/>`}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, m := range findXML(tt.text) {
+			got = append(got, tt.text[m[0]:m[1]])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findXML(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestEmbedYAML tests turning the YAML flow mappings of lines into block style
+func TestEmbedYAML(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`{a: 1, b: no}`, "a: 1\nb: no"},
+		{`cfg {a: {b: "x, y"}, c: [1, {d: 2, e: 3}], f: {}, g} done`, "cfg\n  a:\n    b: \"x, y\"\n  c:\n    - 1\n    - d: 2\n      e: 3\n  f: {}\n  g:\ndone"},
+		{`{'it''s': [a, [b, c]]}`, "'it''s':\n  - a\n  -\n    - b\n    - c"},
+		{`map[a:1] {} {bad} [x]`, `map[a:1] {} {bad} [x]`},
+		{`bad {a: [1, } x`, `bad {a: [1, } x`},
+		{`plain text`, `plain text`},
+	}
+	for _, tt := range tests {
+		if got, _ := embedYAML(tt.text); got != tt.want {
+			t.Errorf("embedYAML(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestYAMLSpans tests finding the keys, scalars and comments of YAML lines
+func TestYAMLSpans(t *testing.T) {
+	tests := []struct {
+		line string
+		want map[string]string
+	}{
+		{"name: web # main", map[string]string{"name": "json.key", "web": "json.string", "# main": "comment"}},
+		{"  - port: 8080", map[string]string{"port": "json.key", "8080": "json.number"}},
+		{"- ~", map[string]string{"~": "json.null"}},
+		{"debug: off", map[string]string{"debug": "json.key", "off": "json.bool"}},
+		{"---", map[string]string{"---": "comment"}},
+		{"script: |", map[string]string{"script": "json.key"}},
+		{"just some text", map[string]string{}},
+		{"url http://x", map[string]string{}},
+		// Log lines are not YAML even if they have a colon
+		{"2020-01-01 12:00:00 ERROR: failed", map[string]string{}},
+		{"first name: Ada", map[string]string{}},
+		{`"first name": Ada`, map[string]string{`"first name"`: "json.key", "Ada": "json.string"}},
+	}
+	for _, tt := range tests {
+		got := make(map[string]string)
+		for _, s := range yamlSpans(tt.line, nil) {
+			for key := range tt.want {
+				if s.style.sequence(colorsTrue) == currentTheme.get(tt.want[key]).sequence(colorsTrue) && tt.line[s.start:s.end] == key {
+					got[key] = tt.want[key]
+				}
+			}
+		}
+		if spans := yamlSpans(tt.line, nil); len(spans) != len(tt.want) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("yamlSpans(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+
+	// Inside a detected document keys can have spaces
+	text, inDoc := embedYAML("user {first name: Ada} was saved")
+	if !reflect.DeepEqual(inDoc, []bool{false, true, false}) {
+		t.Fatalf("embedYAML() lines in document = %v", inDoc)
+	}
+	var keys []string
+	for _, s := range yamlSpans(text, inDoc) {
+		if s.style.sequence(colorsTrue) == currentTheme.get("json.key").sequence(colorsTrue) {
+			keys = append(keys, text[s.start:s.end])
+		}
+	}
+	if !reflect.DeepEqual(keys, []string{"first name"}) {
+		t.Errorf("got keys %q in %q", keys, text)
+	}
+}
+
+// TestMultiDocYAML tests if the lines of a multi document YAML dump are kept and colored one by one
+func TestMultiDocYAML(t *testing.T) {
+	lines := []string{
+		"---",
+		"name: web",
+		"ports:",
+		"  - 80",
+		"  - port: 443 # tls",
+		"---",
+		"name: db",
+		"replicas: 2",
+		"...",
+	}
+	var keys, comments []string
+	for _, line := range lines {
+		text, inDoc := embedYAML(line)
+		if text != line || inDoc != nil {
+			t.Errorf("embedYAML(%q) = %q, %v, want the line as it is", line, text, inDoc)
+		}
+		for _, s := range yamlSpans(text, inDoc) {
+			switch s.style.sequence(colorsTrue) {
+			case currentTheme.get("json.key").sequence(colorsTrue):
+				keys = append(keys, text[s.start:s.end])
+			case currentTheme.get("comment").sequence(colorsTrue):
+				comments = append(comments, text[s.start:s.end])
+			}
+		}
+	}
+	if want := []string{"name", "ports", "port", "name", "replicas"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("got keys %q, want %q", keys, want)
+	}
+	if want := []string{"---", "# tls", "---", "..."}; !reflect.DeepEqual(comments, want) {
+		t.Errorf("got comments %q, want %q", comments, want)
+	}
+}
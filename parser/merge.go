@@ -0,0 +1,408 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// This is the input format of the prompt for the merged stream
+const mergeInputFmt = "Merged stream of %d files | Page [%d/%d]\nEnter page number to navigate\nType help to list all commands or q to quit:"
+
+// This is the list of all commands accepted by the prompt for the merged stream
+const mergeHelpText = `Available commands:
+  <page>        Go to the given page
+  n | p         Go to the next | previous page
+  +<n> | -<n>   Jump n pages forward | backward
+  first | last  Go to the first | last page
+  nm | pm       Go to the next | previous page with a match
+  help          Show this list
+  q             Quit`
+
+// mergeSource is a file of the merged stream
+type mergeSource struct {
+	path string
+	// Short name shown before every line
+	tag     string
+	lines   int
+	matches int
+}
+
+// mergePage is a page of the merged stream
+// It holds the position of every source when the page starts
+type mergePage struct {
+	// Offset of the next line of every source
+	offsets []int64
+	// Number of the next line of every source
+	starts []int
+	// Time of the next line of every source
+	// Lines without a timestamp take the time of the line before them
+	times []time.Time
+	hits  int
+}
+
+// merged is the index of the merged stream
+type merged struct {
+	sources []mergeSource
+	// Pages that can be displayed
+	pages   []mergePage
+	matches int
+}
+
+// mergeLine is a line of the merged stream
+type mergeLine struct {
+	source int
+	number int
+	text   []byte
+}
+
+// mergeCursor reads the lines of a source in order
+type mergeCursor struct {
+	source int
+	r      *bufio.Reader
+	// Offset, number and time of the current line
+	offset int64
+	number int
+	time   time.Time
+	line   []byte
+	// The offset of the line after the current one
+	next int64
+	done bool
+}
+
+// advance reads the next line of the source
+func (c *mergeCursor) advance() {
+	c.offset = c.next
+	c.number++
+	line, err := c.r.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		fatal("Merge lines error:", err)
+	}
+	if len(line) == 0 {
+		c.done, c.line = true, nil
+		return
+	}
+	c.next += int64(len(line))
+	c.line = bytes.TrimRight(line, "\r\n")
+	if t, ok := parseTimestamp(string(c.line)); ok {
+		c.time = t
+	}
+}
+
+// mergeHeap orders the cursors by the time of their current line
+// Cursors with the same time keep the order of the sources
+type mergeHeap []*mergeCursor
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if !h[i].time.Equal(h[j].time) {
+		return h[i].time.Before(h[j].time)
+	}
+	return h[i].source < h[j].source
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(*mergeCursor)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// merger k-way merges the lines of all sources by timestamp
+type merger struct {
+	files   []*os.File
+	cursors []*mergeCursor
+	h       mergeHeap
+}
+
+// newMerger opens all sources at the given page
+// A nil page means the start of every file
+func newMerger(sources []mergeSource, page *mergePage) *merger {
+	m := &merger{}
+	for i, src := range sources {
+		f, err := os.Open(src.path)
+		if err != nil {
+			fatalf("Cannot open file path %s, Error: %v", src.path, err)
+		}
+		c := &mergeCursor{source: i}
+		if page != nil {
+			if _, err := f.Seek(page.offsets[i], io.SeekStart); err != nil {
+				fatal("Cannot seek file page:", err)
+			}
+			c.next, c.number, c.time = page.offsets[i], page.starts[i]-1, page.times[i]
+		}
+		c.r = bufio.NewReader(f)
+		c.advance()
+		m.files = append(m.files, f)
+		m.cursors = append(m.cursors, c)
+		if !c.done {
+			m.h = append(m.h, c)
+		}
+	}
+	heap.Init(&m.h)
+	return m
+}
+
+// next returns the next line of the merged stream
+// The second value is false when all sources were read
+func (m *merger) next() (mergeLine, bool) {
+	if len(m.h) == 0 {
+		return mergeLine{}, false
+	}
+	c := m.h[0]
+	line := mergeLine{source: c.source, number: c.number, text: c.line}
+	c.advance()
+	if c.done {
+		heap.Pop(&m.h)
+	} else {
+		heap.Fix(&m.h, 0)
+	}
+	return line, true
+}
+
+// position returns the position of every source as the start of a page
+func (m *merger) position() mergePage {
+	page := mergePage{
+		offsets: make([]int64, len(m.cursors)),
+		starts:  make([]int, len(m.cursors)),
+		times:   make([]time.Time, len(m.cursors)),
+	}
+	for i, c := range m.cursors {
+		page.offsets[i], page.starts[i], page.times[i] = c.offset, c.number, c.time
+	}
+	return page
+}
+
+// close closes all sources
+func (m *merger) close() {
+	for _, f := range m.files {
+		f.Close()
+	}
+}
+
+// buildMerge reads all files once to index the pages of the merged stream
+func (p *Parser) buildMerge(paths []string) *merged {
+	res := &merged{sources: make([]mergeSource, len(paths))}
+	for i, path := range paths {
+		res.sources[i] = mergeSource{path: path, tag: filepath.Base(path)}
+	}
+	// Files with the same name are told apart by their path
+	names := make(map[string]int)
+	for _, src := range res.sources {
+		names[src.tag]++
+	}
+	for i, src := range res.sources {
+		if names[src.tag] > 1 {
+			if rel, err := filepath.Rel(p.path, src.path); err == nil {
+				res.sources[i].tag = rel
+			} else {
+				res.sources[i].tag = src.path
+			}
+		}
+	}
+	m := newMerger(res.sources, nil)
+	defer m.close()
+	page := m.position()
+	var count int
+	var pageHit bool
+	// endPage keeps the page if it can be displayed
+	endPage := func() {
+		if !p.filtered() || pageHit {
+			res.pages = append(res.pages, page)
+		}
+	}
+	for {
+		if count == p.lines {
+			endPage()
+			page, count, pageHit = m.position(), 0, false
+		}
+		line, ok := m.next()
+		if !ok {
+			break
+		}
+		count++
+		res.sources[line.source].lines++
+		if numHits := p.lineHits(line.text); numHits > 0 {
+			pageHit = true
+			page.hits += numHits
+			res.sources[line.source].matches += numHits
+			res.matches += numHits
+		}
+		// If only an exclusion was provided
+		// every line that is not excluded is a page hit
+		if p.filter == "" && !p.excluded(line.text) {
+			pageHit = true
+		}
+	}
+	if count > 0 {
+		endPage()
+	}
+	return res
+}
+
+// getMergedPage gets the output for a page of the merged stream
+func (p *Parser) getMergedPage(res *merged, page int) string {
+	// Tags are aligned so the lines start in the same column
+	var width int
+	for _, src := range res.sources {
+		if len(src.tag) > width {
+			width = len(src.tag)
+		}
+	}
+	m := newMerger(res.sources, &res.pages[page-1])
+	defer m.close()
+	var output bytes.Buffer
+	for i := 0; i < p.lines; i++ {
+		line, ok := m.next()
+		if !ok {
+			break
+		}
+		if p.excluded(line.text) {
+			continue
+		}
+		tag := currentTheme.list("tags", line.source).sprint(fmt.Sprintf("[%-*s]", width, res.sources[line.source].tag))
+		fmt.Fprintf(&output, "%s %s\n", tag, p.numbered(line.number, p.getOutput(line.number, string(line.text))))
+	}
+	return output.String()
+}
+
+// renderMergeStats renders the table with the stats of every source
+func renderMergeStats(w io.Writer, res *merged) {
+	table := newTable(w)
+	setTableHeader(table, []string{"Source", "File Path", "Number of Lines", "Number of Matches"})
+	for i, src := range res.sources {
+		table.Append([]string{
+			currentTheme.list("tags", i).sprint(src.tag),
+			src.path,
+			strconv.Itoa(src.lines),
+			strconv.Itoa(src.matches),
+		})
+	}
+	fmt.Fprintln(w, info(fmt.Sprintf("Merged stream has %d pages and %d matches", len(res.pages), res.matches)))
+	table.Render()
+}
+
+// runMerge shows all files as a single stream ordered by timestamp
+func (p *Parser) runMerge() {
+	res := p.buildMerge(p.getPaths())
+	if len(res.pages) == 0 {
+		fmt.Printf("%s\n", info("Sorry. Nothing to show here!"))
+		return
+	}
+	numPages := len(res.pages)
+	if p.batch {
+		var w io.Writer
+		switch p.statsOut {
+		case "stdout":
+			w = os.Stdout
+		case "stderr":
+			w = os.Stderr
+		}
+		if w != nil {
+			renderMergeStats(w, res)
+			fmt.Fprintln(w)
+		}
+		if p.from > numPages {
+			exitWithError(fmt.Sprintf("Error! Page number cannot be greater than %d", numPages))
+		}
+		to := p.to
+		if to == 0 || to > numPages {
+			to = numPages
+		}
+		// The colors of the filter terms are told once above the lines
+		fmt.Print(p.legend())
+		for page := p.from; page <= to; page++ {
+			fmt.Print(p.getMergedPage(res, page))
+		}
+		return
+	}
+	page := p.page
+	if page > numPages {
+		fmt.Printf("\n%s\n\n", fail(fmt.Sprintf("Error! Page number cannot be greater than %d", numPages)))
+		return
+	}
+	show := func() {
+		renderMergeStats(os.Stdout, res)
+		fmt.Println()
+		fmt.Print(p.legend())
+		fmt.Println(p.getMergedPage(res, page))
+	}
+	show()
+	if numPages == 1 {
+		return
+	}
+	prompt := func() {
+		fmt.Print(alert(fmt.Sprintf(mergeInputFmt, len(res.sources), page, numPages)), " ")
+	}
+	prompt()
+	input := make(chan string)
+	go scanInput(input)
+	for text := range input {
+		cmd, err := extractNavigation(text)
+		if err == nil {
+			next := page
+			switch cmd.action {
+			case actionQuit:
+				return
+			case actionHelp:
+				fmt.Printf("\n%s\n\n", info(mergeHelpText))
+			case actionGoto:
+				if cmd.id > 1 {
+					err = errors.New("Error! The merged stream has no other files")
+				}
+				next = cmd.page
+			case actionJump:
+				next += cmd.page
+			case actionFirst:
+				next = 1
+			case actionLast:
+				next = numPages
+			case actionNextMatch, actionPrevMatch:
+				next, err = p.findMergedMatch(res, page, cmd.action == actionNextMatch)
+			default:
+				err = errors.New("Error! This command is not available for the merged stream")
+			}
+			if err == nil && next != page {
+				if next < 1 || next > numPages {
+					err = fmt.Errorf("Error! Page number must be between 1 and %d", numPages)
+				} else {
+					page = next
+					fmt.Println()
+					show()
+				}
+			}
+		}
+		if err != nil {
+			fmt.Printf("\n%s\n\n", fail(err.Error()))
+		}
+		prompt()
+	}
+}
+
+// findMergedMatch finds the next or previous page of the merged stream with a match
+func (p *Parser) findMergedMatch(res *merged, page int, forward bool) (int, error) {
+	if p.filter == "" {
+		return 0, errors.New("Error! No filter was provided")
+	}
+	if res.matches == 0 {
+		return 0, errors.New("Error! There are no pages with matches")
+	}
+	step := -1
+	if forward {
+		step = 1
+	}
+	for i := page - 1 + step; i >= 0 && i < len(res.pages); i += step {
+		if res.pages[i].hits > 0 {
+			return i + 1, nil
+		}
+	}
+	return 0, errors.New("Error! There are no more pages with matches")
+}
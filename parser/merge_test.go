@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// TestMerge tests if the lines of all files are merged by timestamp
+// and if every page of the merged stream starts at the right place
+func TestMerge(t *testing.T) {
+	dir, cleanup := writeTestFiles(t, map[string]string{
+		"api.log": "2019-12-10T15:00:01Z api request\n" +
+			"2019-12-10T15:00:04Z api ERROR timeout\n" +
+			"  at handler.go:12\n",
+		"db.log": "2019-12-10T15:00:02Z db slow query\n" +
+			"2019-12-10T15:00:03Z db ERROR lock\n" +
+			"2019-12-10T15:00:05Z db ok\n",
+	})
+	defer cleanup()
+	paths := []string{filepath.Join(dir, "api.log"), filepath.Join(dir, "db.log")}
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	p := &Parser{path: dir, lines: 2}
+	res := p.buildMerge(paths)
+	if len(res.pages) != 3 {
+		t.Fatalf("got %d pages, want 3", len(res.pages))
+	}
+	want := []string{
+		"[api.log] 2019-12-10T15:00:01Z api request\n" +
+			"[db.log ] 2019-12-10T15:00:02Z db slow query\n",
+		"[db.log ] 2019-12-10T15:00:03Z db ERROR lock\n" +
+			"[api.log] 2019-12-10T15:00:04Z api ERROR timeout\n",
+		// The stack trace line keeps the time of the line before it
+		"[api.log]   at handler.go:12\n" +
+			"[db.log ] 2019-12-10T15:00:05Z db ok\n",
+	}
+	for i := range want {
+		if got := p.getMergedPage(res, i+1); got != want[i] {
+			t.Errorf("page %d: got %q, want %q", i+1, got, want[i])
+		}
+	}
+
+	// Only the pages with matches are kept when filtering
+	p = &Parser{path: dir, lines: 2, filter: "ERROR"}
+	res = p.buildMerge(paths)
+	if len(res.pages) != 1 || res.matches != 2 || res.sources[0].matches != 1 || res.sources[1].lines != 3 {
+		t.Errorf("got %d pages, %d matches and sources %+v", len(res.pages), res.matches, res.sources)
+	}
+	if page, err := p.findMergedMatch(res, 1, true); err == nil || err.Error() != "Error! There are no more pages with matches" {
+		t.Errorf("found page %d with a match after the last one (%v)", page, err)
+	}
+	// A filter without matches is not a missing filter
+	p = &Parser{path: dir, lines: 2, filter: "FATAL"}
+	if _, err := p.findMergedMatch(p.buildMerge(paths), 1, true); err == nil || err.Error() != "Error! There are no pages with matches" {
+		t.Errorf("got error %v", err)
+	}
+	p = &Parser{path: dir, lines: 2}
+	if _, err := p.findMergedMatch(p.buildMerge(paths), 1, true); err == nil || err.Error() != "Error! No filter was provided" {
+		t.Errorf("got error %v", err)
+	}
+}
@@ -0,0 +1,364 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// This is the input format which asks the user for new input data
+const inputFmt = "File: %s | Page [%d/%d]\nEnter page number to navigate\nEnter file id and page number separated by a comma to navigate to another file\nType help to list all commands or q to quit:"
+
+// This is the list of all commands accepted by the prompt
+const helpText = `Available commands:
+  <page>        Go to the given page of the current file
+  <id>,<page>   Go to the given page of another file
+  n | p         Go to the next | previous page
+  +<n> | -<n>   Jump n pages forward | backward
+  first | last  Go to the first | last page
+  nm | pm       Go to the next | previous page with a match
+  f <id>        Switch to another file
+  :<line>       Go to the page containing the given line
+  /<text>       Change the filter (a single / removes it)
+  /!<text>      Change the exclusion (a single /! removes it)
+  b <n> [as] <note>
+                Bookmark the n-th line of the current page
+  bookmarks     List all bookmarks
+  bm <n>        Go to the page of bookmark n
+  unbookmark <n>
+                Remove bookmark n
+  timeline [md|json] [file]
+                Export the bookmarks as an incident timeline
+  export <file> Export all matching lines to a file (.gz files are compressed)
+  help          Show this list
+  q             Quit`
+
+// Actions that can be requested from the prompt
+const (
+	actionGoto = iota
+	actionJump
+	actionFirst
+	actionLast
+	actionNextMatch
+	actionPrevMatch
+	actionLine
+	actionFilter
+	actionExclude
+	actionBookmark
+	actionBookmarks
+	actionGotoBookmark
+	actionRemoveBookmark
+	actionTimeline
+	actionExport
+	actionHelp
+	actionQuit
+)
+
+// command is a navigation request typed by the user
+type command struct {
+	action int
+	// File id (0 means the current file)
+	id int
+	// Page number for actionGoto, number of pages for actionJump
+	// or line number for actionLine
+	page int
+	// Filter text for actionFilter and actionExclude
+	// Note for actionBookmark and format for actionTimeline
+	text string
+	// Destination file for actionTimeline and actionExport
+	path string
+}
+
+// navigator keeps track of the current position in the parsed files
+type navigator struct {
+	p *Parser
+	// Stats for all files, including the ones with nothing to show
+	all []stats
+	// Stats for the files that can be displayed
+	fs []stats
+	// Sequence number of the last filter request
+	seq int
+	// Bookmarks for all files
+	marks []bookmark
+	// Histogram of the matches shown after the stats table
+	hist *histogram
+	// Current file id (starts from 1)
+	id int
+	// Current page number (starts from 1)
+	page int
+}
+
+// current returns the stats of the current file
+func (n *navigator) current() stats {
+	return n.fs[n.id-1]
+}
+
+// numPages returns the number of pages of the current file
+func (n *navigator) numPages() int {
+	return len(n.current().offsets)
+}
+
+// show renders the table with file stats and the current page
+func (n *navigator) show() {
+	// Render the table with file stats
+	renderStats(os.Stdout, n.fs, n.id)
+	fmt.Println()
+	if n.p.histogram {
+		renderHistogram(os.Stdout, n.hist)
+		fmt.Println()
+	}
+	// The colors of the filter terms are told above the lines
+	fmt.Print(n.p.legend())
+	// Get the page output and send it to the console
+	stat := n.current()
+	text, err := n.p.getFilePage(stat.path, stat.offsets[n.page-1], stat.firstLine(n.page))
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(text)
+}
+
+// prompt asks the user where to navigate next
+func (n *navigator) prompt() {
+	fmt.Print(alert(fmt.Sprintf(inputFmt, n.current().path, n.page, n.numPages())), " ")
+}
+
+// run executes a navigation command and shows the new page
+func (n *navigator) run(cmd command) error {
+	// Bookmark commands do not change the current page by themselves
+	switch cmd.action {
+	case actionBookmark:
+		return n.addBookmark(cmd.page, cmd.text)
+	case actionBookmarks:
+		return n.listBookmarks()
+	case actionGotoBookmark:
+		return n.gotoBookmark(cmd.page)
+	case actionRemoveBookmark:
+		return n.removeBookmark(cmd.page)
+	case actionTimeline:
+		return n.exportTimeline(cmd.text, cmd.path)
+	case actionExport:
+		// The page range only applies to --export, the prompt exports all pages
+		total, err := n.p.exportTo(cmd.path, n.fs, 1, 0)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n%s\n\n", info(fmt.Sprintf("%d matching lines were exported to %s", total, cmd.path)))
+		return nil
+	}
+	id, page := n.id, n.page
+	switch cmd.action {
+	case actionGoto:
+		// Only if id > 0 then the user wants to change the current file
+		if cmd.id > 0 {
+			id = cmd.id
+		}
+		page = cmd.page
+	case actionJump:
+		page += cmd.page
+	case actionFirst:
+		page = 1
+	case actionLast:
+		page = len(n.fs[id-1].offsets)
+	case actionNextMatch, actionPrevMatch:
+		var err error
+		if id, page, err = n.findMatch(cmd.action == actionNextMatch); err != nil {
+			return err
+		}
+	case actionLine:
+		stat := n.current()
+		if cmd.page < 1 || cmd.page > stat.lines {
+			return fmt.Errorf("Error! Line number must be between 1 and %d", stat.lines)
+		}
+		var ok bool
+		if page, ok = stat.findLine(cmd.page); !ok {
+			return errors.New("Error! The line is hidden by the current filter")
+		}
+	}
+	// Validate the destination before moving there
+	if id < 1 || id > len(n.fs) {
+		return fmt.Errorf("Error! ID number must be between 1 and %d", len(n.fs))
+	}
+	if numPages := len(n.fs[id-1].offsets); page < 1 || page > numPages {
+		return fmt.Errorf("Error! Page number must be between 1 and %d", numPages)
+	}
+	n.id, n.page = id, page
+	fmt.Println()
+	n.show()
+	return nil
+}
+
+// findMatch looks for the next (or previous) page that has at least 1 match
+// The search continues into the following (or preceding) files
+func (n *navigator) findMatch(forward bool) (int, int, error) {
+	if n.p.filter == "" {
+		return 0, 0, errors.New("Error! No filter was provided")
+	}
+	step := 1
+	if !forward {
+		step = -1
+	}
+	id, page := n.id, n.page+step
+	for id >= 1 && id <= len(n.fs) {
+		hits := n.fs[id-1].hits
+		for ; page >= 1 && page <= len(hits); page += step {
+			if hits[page-1] > 0 {
+				return id, page, nil
+			}
+		}
+		id += step
+		if id < 1 || id > len(n.fs) {
+			break
+		}
+		// Moving forward into a file starts from its first page and backwards from its last page
+		page = 1
+		if !forward {
+			page = len(n.fs[id-1].hits)
+		}
+	}
+	return 0, 0, errors.New("Error! There are no more pages with matches")
+}
+
+// extractNavigation parses the text typed at the prompt into a command
+func extractNavigation(s string) (command, error) {
+	// Filters are taken as they are, spaces included
+	if strings.HasPrefix(s, "/!") {
+		return command{action: actionExclude, text: s[2:]}, nil
+	}
+	if strings.HasPrefix(s, "/") {
+		return command{action: actionFilter, text: s[1:]}, nil
+	}
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return command{}, errors.New("Error! A valid number is required")
+	}
+	switch strings.ToLower(fields[0]) {
+	case "n", "next":
+		return command{action: actionJump, page: 1}, nil
+	case "p", "prev":
+		return command{action: actionJump, page: -1}, nil
+	case "first":
+		return command{action: actionFirst}, nil
+	case "last":
+		return command{action: actionLast}, nil
+	case "nm":
+		return command{action: actionNextMatch}, nil
+	case "pm":
+		return command{action: actionPrevMatch}, nil
+	case "help", "h", "?":
+		return command{action: actionHelp}, nil
+	case "q", "quit", "exit":
+		return command{action: actionQuit}, nil
+	case "f", "file":
+		if len(fields) != 2 {
+			return command{}, errors.New("Error! A file id is required")
+		}
+		id, err := strconv.Atoi(fields[1])
+		if err != nil || id < 1 {
+			return command{}, errors.New("Error! A valid file id is required")
+		}
+		return command{action: actionGoto, id: id, page: 1}, nil
+	case "b", "bookmark":
+		return extractBookmark(s)
+	case "bookmarks":
+		return command{action: actionBookmarks}, nil
+	case "bm", "unbookmark":
+		if len(fields) != 2 {
+			return command{}, errors.New("Error! A bookmark number is required")
+		}
+		num, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return command{}, errors.New("Error! A valid bookmark number is required")
+		}
+		if strings.ToLower(fields[0]) == "bm" {
+			return command{action: actionGotoBookmark, page: num}, nil
+		}
+		return command{action: actionRemoveBookmark, page: num}, nil
+	case "export":
+		if len(fields) != 2 {
+			return command{}, errors.New("Error! An export file is required")
+		}
+		return command{action: actionExport, path: fields[1]}, nil
+	case "timeline":
+		cmd := command{action: actionTimeline, text: "md"}
+		if len(fields) > 3 {
+			return command{}, errors.New("Error! Usage: timeline [md|json] [file]")
+		}
+		if len(fields) > 1 {
+			cmd.text = strings.ToLower(fields[1])
+		}
+		if len(fields) > 2 {
+			cmd.path = fields[2]
+		}
+		return cmd, nil
+	}
+	s = strings.TrimSpace(s)
+	// Line numbers are prefixed by a colon
+	if strings.HasPrefix(s, ":") {
+		line, err := strconv.Atoi(strings.TrimSpace(s[1:]))
+		if err != nil {
+			return command{}, errors.New("Error! A valid line number is required")
+		}
+		return command{action: actionLine, page: line}, nil
+	}
+	// Relative jumps are signed numbers
+	if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		delta, err := strconv.Atoi(s)
+		if err != nil {
+			return command{}, errors.New("Error! A valid number is required")
+		}
+		return command{action: actionJump, page: delta}, nil
+	}
+	nums := strings.Split(s, ",")
+	if len(nums) > 2 {
+		return command{}, errors.New("Error! More than 2 numbers provided")
+	}
+	// Only page number counts in this situation
+	// The user wants to use the current file and only change the page
+	// So we return id=0 as a convention here
+	if len(nums) == 1 {
+		page, err := strconv.Atoi(strings.Trim(nums[0], " "))
+		if err != nil {
+			return command{}, fmt.Errorf("Error! Unknown command %q. Type help to list all commands", s)
+		}
+		return command{action: actionGoto, page: page}, nil
+	}
+	// The user wants to change the file and page implicitly
+	id, err := strconv.Atoi(strings.Trim(nums[0], " "))
+	if err != nil {
+		return command{}, errors.New("Error! A valid number is required")
+	}
+	page, err := strconv.Atoi(strings.Trim(nums[1], " "))
+	if err != nil {
+		return command{}, errors.New("Error! A valid number is required")
+	}
+	return command{action: actionGoto, id: id, page: page}, nil
+}
+
+// extractBookmark parses a bookmark command
+// e.g. bookmark 3 as 'first 502'
+func extractBookmark(s string) (command, error) {
+	fields := strings.SplitN(strings.TrimSpace(s), " ", 3)
+	if len(fields) < 2 {
+		return command{}, errors.New("Error! A line number is required")
+	}
+	line, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return command{}, errors.New("Error! A valid line number is required")
+	}
+	var note string
+	if len(fields) == 3 {
+		note = strings.TrimSpace(fields[2])
+		// The "as" keyword is optional
+		if strings.HasPrefix(note, "as ") {
+			note = strings.TrimSpace(note[3:])
+		}
+		// Quotes around the note are optional too
+		if len(note) >= 2 && (note[0] == '\'' || note[0] == '"') && note[len(note)-1] == note[0] {
+			note = note[1 : len(note)-1]
+		}
+	}
+	return command{action: actionBookmark, page: line, text: note}, nil
+}
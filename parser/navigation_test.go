@@ -0,0 +1,176 @@
+package parser
+
+import "testing"
+
+// TestExtractNavigation tests if the prompt input
+// is translated into the right navigation command
+func TestExtractNavigation(t *testing.T) {
+	tests := []struct {
+		input string
+		cmd   command
+		fail  bool
+	}{
+		{"3", command{action: actionGoto, page: 3}, false},
+		{" 2, 7 ", command{action: actionGoto, id: 2, page: 7}, false},
+		{"n", command{action: actionJump, page: 1}, false},
+		{"p", command{action: actionJump, page: -1}, false},
+		{"+5", command{action: actionJump, page: 5}, false},
+		{"-3", command{action: actionJump, page: -3}, false},
+		{"first", command{action: actionFirst}, false},
+		{"last", command{action: actionLast}, false},
+		{"nm", command{action: actionNextMatch}, false},
+		{"pm", command{action: actionPrevMatch}, false},
+		{"f 3", command{action: actionGoto, id: 3, page: 1}, false},
+		{":120", command{action: actionLine, page: 120}, false},
+		{":x", command{}, true},
+		{"q", command{action: actionQuit}, false},
+		{"help", command{action: actionHelp}, false},
+		{"/time out", command{action: actionFilter, text: "time out"}, false},
+		{"/", command{action: actionFilter}, false},
+		{"/!DEBUG", command{action: actionExclude, text: "DEBUG"}, false},
+		{"/!", command{action: actionExclude}, false},
+		{"bookmark 3 as 'first 502'", command{action: actionBookmark, page: 3, text: "first 502"}, false},
+		{"b 7 retry storm", command{action: actionBookmark, page: 7, text: "retry storm"}, false},
+		{"b 2", command{action: actionBookmark, page: 2}, false},
+		{"bookmarks", command{action: actionBookmarks}, false},
+		{"bm 2", command{action: actionGotoBookmark, page: 2}, false},
+		{"unbookmark 1", command{action: actionRemoveBookmark, page: 1}, false},
+		{"timeline", command{action: actionTimeline, text: "md"}, false},
+		{"timeline json out.json", command{action: actionTimeline, text: "json", path: "out.json"}, false},
+		{"export out.log.gz", command{action: actionExport, path: "out.log.gz"}, false},
+		{"export", command{}, true},
+		{"b x", command{}, true},
+		{"bm", command{}, true},
+		{"", command{}, true},
+		{"f", command{}, true},
+		{"f x", command{}, true},
+		{"+x", command{}, true},
+		{"1,2,3", command{}, true},
+		{"a,2", command{}, true},
+		{"unknown", command{}, true},
+	}
+
+	for _, tc := range tests {
+		cmd, err := extractNavigation(tc.input)
+		if (err != nil) != tc.fail {
+			t.Fatalf("With input %q: expected failure %v; got error %v", tc.input, tc.fail, err)
+		}
+		if cmd != tc.cmd {
+			t.Fatalf("With input %q: expected %+v; got %+v", tc.input, tc.cmd, cmd)
+		}
+	}
+}
+
+// TestNavigatorFindMatch tests looking for pages with matches across the files in both directions
+func TestNavigatorFindMatch(t *testing.T) {
+	n := &navigator{
+		p: &Parser{filter: "ERROR"},
+		fs: []stats{
+			{hits: []int{0, 1, 1}},
+			{hits: []int{0, 0, 1}},
+		},
+	}
+	tests := []struct {
+		id, page int
+		forward  bool
+		wantID   int
+		wantPage int
+		fail     bool
+	}{
+		{1, 2, true, 1, 3, false},
+		{1, 3, true, 2, 3, false},
+		{1, 1, true, 1, 2, false},
+		{2, 3, true, 0, 0, true},
+		{1, 3, false, 1, 2, false},
+		{2, 3, false, 1, 3, false},
+		{2, 1, false, 1, 3, false},
+		{1, 2, false, 0, 0, true},
+		{1, 1, false, 0, 0, true},
+	}
+	for _, tc := range tests {
+		n.id, n.page = tc.id, tc.page
+		id, page, err := n.findMatch(tc.forward)
+		if (err != nil) != tc.fail {
+			t.Fatalf("From %d,%d forward %v: expected failure %v; got error %v", tc.id, tc.page, tc.forward, tc.fail, err)
+		}
+		if id != tc.wantID || page != tc.wantPage {
+			t.Errorf("From %d,%d forward %v: expected %d,%d; got %d,%d", tc.id, tc.page, tc.forward, tc.wantID, tc.wantPage, id, page)
+		}
+	}
+	n.p.filter = ""
+	if _, _, err := n.findMatch(true); err == nil {
+		t.Error("expected an error without a filter")
+	}
+}
+
+// TestStatsLines tests finding the first line of a displayed page and the page of a line
+// Pages hold a different number of lines like in dedupe mode, and the pages 1 and 3 are hidden
+func TestStatsLines(t *testing.T) {
+	s := stats{
+		pages:   []int64{0, 10, 20, 30},
+		starts:  []int{1, 4, 7, 10},
+		lines:   12,
+		offsets: []int64{10, 30},
+	}
+	for page, want := range map[int]int{1: 4, 2: 10} {
+		if got := s.firstLine(page); got != want {
+			t.Errorf("firstLine(%d) = %d, want %d", page, got, want)
+		}
+	}
+	tests := []struct {
+		line int
+		page int
+		ok   bool
+	}{
+		{1, 0, false},
+		{3, 0, false},
+		{4, 1, true},
+		{6, 1, true},
+		{7, 0, false},
+		{10, 2, true},
+		{12, 2, true},
+		{0, 0, false},
+	}
+	for _, tc := range tests {
+		page, ok := s.findLine(tc.line)
+		if page != tc.page || ok != tc.ok {
+			t.Errorf("findLine(%d) = %d, %v, want %d, %v", tc.line, page, ok, tc.page, tc.ok)
+		}
+	}
+}
+
+// TestActionLine tests going to the page of a line from the prompt
+func TestActionLine(t *testing.T) {
+	path, cleanup := writeFilterLog(t)
+	defer cleanup()
+	// Only the pages 1 and 5 have errors, so they are the displayed pages 1 and 2
+	p := New(path, Options{Text: "plain", Filter: "ERROR", Lines: 2, Page: 1, NoColor: true})
+	fs := visibleStats(p.collectStats())
+	tests := []struct {
+		input string
+		page  int
+		fail  bool
+	}{
+		{":9", 2, false},
+		{":10", 2, false},
+		{":1", 1, false},
+		// The line is on a page hidden by the filter
+		{":4", 2, true},
+		{":13", 2, true},
+	}
+	n := &navigator{p: p, all: fs, fs: fs, id: 1, page: 2}
+	for _, tc := range tests {
+		cmd, err := extractNavigation(tc.input)
+		if err != nil {
+			t.Fatalf("With input %q: %v", tc.input, err)
+		}
+		n.page = 2
+		err = n.run(cmd)
+		if (err != nil) != tc.fail {
+			t.Fatalf("With input %q: expected failure %v; got error %v", tc.input, tc.fail, err)
+		}
+		if n.page != tc.page {
+			t.Errorf("With input %q: expected page %d; got %d", tc.input, tc.page, n.page)
+		}
+	}
+}
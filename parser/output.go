@@ -0,0 +1,253 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Accepted structured output formats
+var outputFormats = []string{
+	"json",
+	"ndjson",
+	"csv",
+}
+
+// span is the position of a filter match inside a line
+type span struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+// record is a line emitted by the structured output
+type record struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	// Byte offset of the line in the file
+	Offset int64 `json:"offset"`
+	// Page number as displayed by the navigation
+	Page    int    `json:"page"`
+	Text    string `json:"text"`
+	Matches []span `json:"matches"`
+	// Fields parsed from the line for structured text types
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// summary is the machine readable version of the stats table
+type summary struct {
+	ID      int    `json:"id"`
+	File    string `json:"file"`
+	Pages   int    `json:"pages"`
+	Lines   int    `json:"lines"`
+	Matches int    `json:"matches"`
+}
+
+// recordWriter writes records in one of the structured output formats
+type recordWriter struct {
+	format string
+	w      *bufio.Writer
+	csv    *csv.Writer
+	// Number of items written so far
+	count int
+}
+
+// newRecordWriter returns a writer for the given format
+func newRecordWriter(format string, w io.Writer) *recordWriter {
+	rw := &recordWriter{format: format, w: bufio.NewWriter(w)}
+	if format == "csv" {
+		rw.csv = csv.NewWriter(rw.w)
+	}
+	return rw
+}
+
+// header starts the output. CSV needs the column names
+func (rw *recordWriter) header(columns []string) {
+	switch rw.format {
+	case "json":
+		rw.w.WriteString("[")
+	case "csv":
+		rw.csv.Write(columns)
+	}
+}
+
+// write emits a single item
+// CSV rows must be given, the other formats encode the value itself
+func (rw *recordWriter) write(v interface{}, row []string) {
+	if rw.format == "csv" {
+		rw.csv.Write(row)
+		rw.count++
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		fatal("Output encoding error: ", err)
+	}
+	if rw.format == "json" {
+		if rw.count > 0 {
+			rw.w.WriteString(",")
+		}
+		rw.w.WriteString("\n  ")
+	}
+	rw.w.Write(data)
+	if rw.format == "ndjson" {
+		rw.w.WriteString("\n")
+	}
+	rw.count++
+}
+
+// close ends the output and flushes everything
+func (rw *recordWriter) close() {
+	switch rw.format {
+	case "json":
+		if rw.count > 0 {
+			rw.w.WriteString("\n")
+		}
+		rw.w.WriteString("]\n")
+	case "csv":
+		rw.csv.Flush()
+		if err := rw.csv.Error(); err != nil {
+			fatal("Output encoding error: ", err)
+		}
+	}
+	if err := rw.w.Flush(); err != nil {
+		fatal("Output error: ", err)
+	}
+}
+
+// runOutput prints the matching lines (or the stats summary) in a structured format
+func (p *Parser) runOutput(fs []stats) {
+	rw := newRecordWriter(p.output, os.Stdout)
+	defer rw.close()
+	// Only the stats are printed when the user asks for the summary
+	if p.summary {
+		rw.header([]string{"id", "file", "pages", "lines", "matches"})
+		for i, stat := range fs {
+			s := summary{ID: i + 1, File: stat.path, Pages: len(stat.offsets), Lines: stat.lines, Matches: stat.matches}
+			rw.write(s, []string{
+				strconv.Itoa(s.ID),
+				s.File,
+				strconv.Itoa(s.Pages),
+				strconv.Itoa(s.Lines),
+				strconv.Itoa(s.Matches),
+			})
+		}
+		return
+	}
+	rw.header([]string{"file", "line", "offset", "page", "text", "matches", "fields"})
+	for _, stat := range fs {
+		err := p.eachRecord(stat, func(r record) bool {
+			var matches []string
+			for _, m := range r.Matches {
+				matches = append(matches, fmt.Sprintf("%d-%d", m.Start, m.End))
+			}
+			var fields string
+			if r.Fields != nil {
+				data, _ := json.Marshal(r.Fields)
+				fields = string(data)
+			}
+			rw.write(r, []string{
+				r.File,
+				strconv.Itoa(r.Line),
+				strconv.FormatInt(r.Offset, 10),
+				strconv.Itoa(r.Page),
+				r.Text,
+				strings.Join(matches, ";"),
+				fields,
+			})
+			return true
+		})
+		if err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// eachRecord calls fn for every matching line on the displayed pages of a file
+// Without a filter every line that is not excluded is a match
+// In dedupe mode only the first line of every run of duplicates is a record, just like on the pages
+// It stops as soon as fn returns false
+func (p *Parser) eachRecord(stat stats, fn func(r record) bool) error {
+	// Open the file
+	f, err := os.Open(stat.path)
+	if err != nil {
+		return fmt.Errorf("Cannot open file path %s, Error: %v", stat.path, err)
+	}
+	defer f.Close()
+	for page, offset := range stat.offsets {
+		// Navigate to the page offset
+		if _, err = f.Seek(offset, io.SeekStart); err != nil {
+			return fmt.Errorf("Cannot seek file page: %v", err)
+		}
+		r := bufio.NewReaderSize(f, 64*1024)
+		// The page ends where the next one starts
+		// In dedupe mode it can hold more lines than the page size
+		// The last page ends at the end of file
+		end := int64(-1)
+		if i := sort.Search(len(stat.pages), func(i int) bool { return stat.pages[i] > offset }); i < len(stat.pages) {
+			end = stat.pages[i]
+		}
+		number := stat.firstLine(page + 1)
+		// In dedupe mode this is the key of the previous line
+		var lastKey string
+		for position := offset; end < 0 || position < end; number++ {
+			line, err := r.ReadBytes('\n')
+			if len(line) > 0 {
+				lineOffset := position
+				position += int64(len(line))
+				line = bytes.TrimRight(line, "\r\n")
+				// Pages always start with a new run of lines
+				duplicate := false
+				if p.dedupe != "" {
+					key := p.dedupeKey(line)
+					duplicate = lineOffset > offset && key == lastKey
+					lastKey = key
+				}
+				spans := p.matchSpans(line)
+				if !duplicate && ((p.filter == "" && !p.excluded(line)) || len(spans) > 0) {
+					// Sensitive values are hidden from the text and the fields
+					// Match positions still point to the line in the file
+					text := p.redact(string(line))
+					rec := record{
+						File:    stat.path,
+						Line:    number,
+						Offset:  lineOffset,
+						Page:    page + 1,
+						Text:    text,
+						Matches: []span{},
+						Fields:  p.parseFields(text),
+					}
+					for _, s := range spans {
+						rec.Matches = append(rec.Matches, span{Start: s[0], End: s[1], Text: p.redact(string(line[s[0]:s[1]]))})
+					}
+					if !fn(rec) {
+						return nil
+					}
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("Output lines error: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseFields extracts the fields of a line for structured text types
+// It returns nil when the line has no fields
+func (p *Parser) parseFields(line string) map[string]interface{} {
+	if p.text != "json" {
+		return nil
+	}
+	return jsonFields(line)
+}
@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRecords returns records with characters that need escaping in every format
+func testRecords() []record {
+	return []record{
+		{File: "app.log", Line: 1, Offset: 0, Page: 1, Text: `ERROR "quoted", with comma`, Matches: []span{{0, 5, "ERROR"}}},
+		{File: "app.log", Line: 4, Offset: 42, Page: 2, Text: `{"level":"error"}`, Matches: []span{}, Fields: map[string]interface{}{"level": "error"}},
+	}
+}
+
+// writeRecords writes the test records with the given format
+func writeRecords(format string) string {
+	var b bytes.Buffer
+	rw := newRecordWriter(format, &b)
+	rw.header([]string{"file", "line", "text"})
+	for _, r := range testRecords() {
+		rw.write(r, []string{r.File, "1", r.Text})
+	}
+	rw.close()
+	return b.String()
+}
+
+// TestRecordWriterNDJSON tests if every record is a JSON document on its own line
+func TestRecordWriterNDJSON(t *testing.T) {
+	out := writeRecords("ndjson")
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	want := testRecords()
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %q", len(lines), len(want), out)
+	}
+	for i, line := range lines {
+		var got record
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if got.Text != want[i].Text || got.Line != want[i].Line || got.Offset != want[i].Offset || len(got.Matches) != len(want[i].Matches) {
+			t.Errorf("line %d: got %+v, want %+v", i+1, got, want[i])
+		}
+	}
+	// Records without fields do not have the key at all
+	if strings.Contains(lines[0], `"fields"`) || !strings.Contains(lines[1], `"fields":{"level":"error"}`) {
+		t.Errorf("got %q", out)
+	}
+	// Records without matches still have an empty list
+	if !strings.Contains(lines[1], `"matches":[]`) {
+		t.Errorf("got %q", lines[1])
+	}
+}
+
+// TestRecordWriterJSON tests if the records are written as a single array
+func TestRecordWriterJSON(t *testing.T) {
+	var got []record
+	if err := json.Unmarshal([]byte(writeRecords("json")), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Fields["level"] != "error" {
+		t.Errorf("got %+v", got)
+	}
+	// An empty output is still a valid array
+	var b bytes.Buffer
+	rw := newRecordWriter("json", &b)
+	rw.header(nil)
+	rw.close()
+	if b.String() != "[]\n" {
+		t.Errorf("got %q", b.String())
+	}
+}
+
+// TestRecordWriterCSV tests if the rows follow the header and are quoted when needed
+func TestRecordWriterCSV(t *testing.T) {
+	out := writeRecords("csv")
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"file", "line", "text"},
+		{"app.log", "1", `ERROR "quoted", with comma`},
+		{"app.log", "1", `{"level":"error"}`},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d: %q", len(rows), len(want), out)
+	}
+	for i := range want {
+		if strings.Join(rows[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("row %d: got %q, want %q", i, rows[i], want[i])
+		}
+	}
+	if !strings.Contains(out, `"ERROR ""quoted"", with comma"`) {
+		t.Errorf("got %q", out)
+	}
+}
+
+// TestEachRecordDedupe tests if the records of a page are read up to the next page in dedupe mode
+// Pages hold more lines than the page size when duplicates are collapsed
+func TestEachRecordDedupe(t *testing.T) {
+	path, cleanup := writeTestFile(t, strings.Repeat("same line\n", 5)+"other 1\nother 2\n")
+	defer cleanup()
+
+	tests := []struct {
+		dedupe string
+		lines  []int
+		pages  []int
+	}{
+		{"", []int{1, 2, 3, 4, 5, 6, 7}, []int{1, 1, 2, 2, 3, 3, 4}},
+		// Duplicates are a single record, like the single line shown on the page
+		{"exact", []int{1, 6, 7}, []int{1, 1, 2}},
+	}
+	for _, tt := range tests {
+		p := New(path, Options{Text: "plain", Lines: 2, Page: 1, NoColor: true, Dedupe: tt.dedupe})
+		var lines, pages []int
+		for _, stat := range p.collectStats() {
+			err := p.eachRecord(stat, func(r record) bool {
+				lines = append(lines, r.Line)
+				pages = append(pages, r.Page)
+				return true
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !reflect.DeepEqual(lines, tt.lines) || !reflect.DeepEqual(pages, tt.pages) {
+			t.Errorf("%q: got lines %v on pages %v, want %v on %v", tt.dedupe, lines, pages, tt.lines, tt.pages)
+		}
+	}
+}
@@ -5,33 +5,164 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
-	"github.com/olekukonko/tablewriter"
+	"golang.org/x/term"
 )
 
 // Parser type definition
 type Parser struct {
-	path   string
-	text   string
-	filter string
-	lines  int
-	page   int
-	regex  *regexp.Regexp
-	exts   []string
+	path      string
+	text      string
+	filter    string
+	exclude   string
+	lines     int
+	page      int
+	withRegex bool
+	regex     *regexp.Regexp
+	// Parts of the regex filter colored on their own
+	terms       []filterTerm
+	exReg       *regexp.Regexp
+	exts        []string
+	noColor     bool
+	lineNumbers bool
+	tui         bool
+	batch       bool
+	// Page range printed in batch mode (to = 0 means the last page)
+	from, to int
+	// Where the stats table goes in batch mode
+	statsOut string
+	// Grep compatible output mode
+	grep int
+	// Structured output format
+	output string
+	// Print only the stats in the structured output
+	summary bool
+	// File where the matching lines are exported
+	export string
+	// Number of context lines around exported matches
+	context int
+	// Compress the exported file
+	compress bool
+	// Show the histogram of matches after the stats table
+	histogram bool
+	// Size of the histogram buckets (0 means automatic)
+	bucket time.Duration
+	// How consecutive duplicates are collapsed (empty means they are not)
+	dedupe string
+	// Show all files as a single stream ordered by timestamp
+	merge bool
+	// The file is a unified diff and its lines are colored by kind
+	diff bool
+	// Hides sensitive values in the output and the exports (nil means nothing is hidden)
+	redactor *redactor
+	// Formats the JSON of the lines when the text type is json
+	jsonFmt *jsonFormatter
+}
+
+// Options holds the settings that define how the files are parsed and displayed
+type Options struct {
+	// Text type to parse
+	Text string
+	// Text to filter by
+	Filter string
+	// Hide lines containing this text
+	Exclude string
+	// Number of lines per page
+	Lines int
+	// Current page number
+	Page int
+	// Disable color output
+	NoColor bool
+	// Enable regex support for the filter and the exclusion
+	WithRegex bool
+	// Accepted file extensions (comma separated) for directory paths
+	Ext string
+	// Show the line number before every line
+	LineNumbers bool
+	// Browse the files in a full screen terminal navigator
+	TUI bool
+	// Print the pages and exit without asking for navigation
+	// It is enabled automatically when the output is not a terminal
+	Batch bool
+	// Page range printed in batch mode (e.g. 3, 3-7 or 3-)
+	// All pages are printed when empty
+	Pages string
+	// Where the stats table goes in batch mode (stdout/stderr/none)
+	Stats string
+	// Print matching lines as path:line:content
+	Grep bool
+	// Print every match as path:line:column:match
+	OnlyMatching bool
+	// Print the number of matching lines for every file
+	Count bool
+	// Print only the paths of the files with matches
+	FilesWithMatches bool
+	// Print only the paths of the files without matches
+	FilesWithoutMatch bool
+	// Structured output format (json/ndjson/csv)
+	Output string
+	// Print only the stats in the structured output
+	Summary bool
+	// Export the matching lines to this file and exit
+	Export string
+	// Number of lines to export before and after every match
+	Context int
+	// Compress the exported file with gzip
+	Compress bool
+	// Show the histogram of matches over time after the stats table
+	Histogram bool
+	// Size of the histogram buckets (e.g. 30s, 5m, 1h or 1d)
+	// It is chosen automatically when empty and enables the histogram when set
+	Bucket string
+	// Collapse consecutive duplicate lines into 1 line with a counter
+	// Lines must be identical (exact) or identical after masking numbers and timestamps (masked)
+	Dedupe string
+	// Merge the lines of all files into a single stream ordered by timestamp
+	Merge bool
+	// Hide emails, IPs, tokens, card numbers and AWS keys in the output and the exports
+	// Values are replaced by their kind (mask), their kind and a stable hash (hash) or removed (drop)
+	Redact string
+	// Extra regex rules of values to hide
+	// Only the group called value is hidden when there is one
+	RedactRules []string
+	// Name of a saved search used as the filter
+	// Saved searches are regex so regex support is enabled
+	Search string
+	// Built-in color theme (dark/light/high-contrast)
+	// Colors can be changed one by one in the config files
+	Theme string
+	// JSON objects and arrays nested deeper are collapsed (0 means they never are)
+	JSONDepth int
+	// Print every JSON on a single line
+	JSONCompact bool
+	// JSON fields always printed in full and indented, separated by commas
+	// Nested fields are separated by dots, e.g. request.headers
+	JSONExpand string
 }
 
 // stats for parsed files
 type stats struct {
-	path    string
+	path string
+	// Offsets of all pages in the file
+	pages []int64
+	// Number of the first line of every page in the file
+	starts []int
+	// Total number of lines in the file
+	lines int
+	// Offsets of the pages that can be displayed
+	// These are the pages left after filtering
 	offsets []int64
+	// Number of matches on every page from offsets
+	hits    []int
 	matches int
 }
 
@@ -39,9 +170,6 @@ type stats struct {
 // For very large lines it is safe to put a larger buffer
 const scanBuf = 64 * 1024 * 1024
 
-// This is the input format which asks the user for new input data
-const inputFmt = "File: %s | Page [%d/%d]\nEnter page number to navigate\nEnter file id and page number separated by a comma to navigate to another file\nPress Ctrl+C if you want to quit:"
-
 const (
 	// Graphical display of a checkmark
 	yesMark = "\u2714"
@@ -49,8 +177,18 @@ const (
 	noMark = "\u2715"
 )
 
-// Initial json Regexp
-var jsonReg = regexp.MustCompile(`\[?{".*:.*}\]?`)
+// Accepted ways to find duplicate lines in dedupe mode
+var dedupeModes = []string{
+	"exact",
+	"masked",
+}
+
+// Accepted destinations for the stats table in batch mode
+var statsOutputs = []string{
+	"stdout",
+	"stderr",
+	"none",
+}
 
 // Current accepted text types
 // This information is useful to let the parser know
@@ -58,18 +196,56 @@ var jsonReg = regexp.MustCompile(`\[?{".*:.*}\]?`)
 var textTypes = []string{
 	"plain",
 	"json",
+	"xml",
+	"yaml",
 }
 
-// Color functions to help display meaningful input
-var (
-	success = color.New(color.FgHiGreen, color.Bold).SprintFunc()
-	fail    = color.New(color.FgHiWhite, color.BgRed, color.Bold).SprintFunc()
-	alert   = color.New(color.FgHiYellow, color.Bold).SprintFunc()
-	info    = color.New(color.FgHiMagenta, color.Bold).SprintFunc()
-)
+// BatchMode tells if the pages are printed without asking for navigation
+// Scripts and pipes cannot answer the navigation prompt
+// so batch mode is enabled when the output is not a terminal
+func BatchMode(opts Options) bool {
+	return opts.Batch || (!opts.TUI && !term.IsTerminal(int(os.Stdout.Fd())))
+}
 
 // New returns a new parser object
-func New(path, text, filter string, lines, page int, noColor, withRegex bool, ext string) *Parser {
+func New(path string, opts Options) *Parser {
+	text, filter, exclude := opts.Text, opts.Filter, opts.Exclude
+	lines, page, ext := opts.Lines, opts.Page, opts.Ext
+	noColor, withRegex := opts.NoColor, opts.WithRegex
+	// A saved search takes the place of the filter
+	if opts.Search != "" {
+		if filter != "" {
+			exitWithError("Error! Option flags -search and -filter cannot be combined")
+		}
+		s, err := findSearch(searchPaths(), opts.Search)
+		if err != nil {
+			exitWithError(err.Error())
+		}
+		// The output may be piped so the notice goes to stderr
+		if s.shadows != "" {
+			fmt.Fprintln(os.Stderr, alert(s.shadowNotice(opts.Search)))
+		}
+		filter, withRegex = s.Query, true
+	}
+	// Grep compatible modes exit with status 2 on errors
+	grep := opts.grepMode()
+	if grep != grepOff {
+		errorStatus = statusError
+	}
+	// Check if a valid output format was provided
+	if opts.Output != "" && !stringInSlice(opts.Output, outputFormats) {
+		exitWithError(fmt.Sprintf("Error! Accepted output formats are: %s", strings.Join(outputFormats, ", ")))
+	}
+	if opts.Summary && opts.Output == "" {
+		exitWithError("Error! Option flag -summary requires an output format")
+	}
+	// Check if a valid context value was provided
+	if opts.Context < 0 {
+		exitWithError("Error! Option flag -context cannot be negative")
+	}
+	if opts.FilesWithMatches && opts.FilesWithoutMatch {
+		exitWithError("Error! Option flags -files-with-matches and -files-without-match cannot be combined")
+	}
 	// Check if a path was provided
 	if path == "" {
 		exitWithError("Error! Path is required")
@@ -92,219 +268,407 @@ func New(path, text, filter string, lines, page int, noColor, withRegex bool, ex
 	if strings.HasPrefix(path, "~") {
 		user, err := user.Current()
 		if err != nil {
-			log.Fatal("Cannot get current user:", err)
+			fatal("Cannot get current user:", err)
 		}
 		path = strings.Replace(path, "~", user.HomeDir, 1)
 	}
 	// Check if ext flag is present for directory path
 	info, err := os.Stat(path)
 	if err != nil {
-		log.Fatalf("Cannot get file stat info for %s. Error: %v", path, err)
+		fatalf("Cannot get file stat info for %s. Error: %v", path, err)
 	}
 	if info.IsDir() && ext == "" {
 		exitWithError("Extensions flag is required for directory paths")
 	}
 	// Disables colorized output
+	// NO_COLOR counts as an explicit request too
+	noColor = noColor || noColorEnv()
 	if noColor {
 		color.NoColor = true
 	}
+	// Pick the colors of the output
+	setTheme(opts.Theme)
+	// If no filter is provided then regex is useless
+	// In this case notify the user
+	if withRegex && filter == "" && exclude == "" {
+		exitWithError("Error! Regex is present but no filter value was provided!")
+	}
 	// Enable regex support is user asks for it
-	var regex *regexp.Regexp
-	// Attach regexp to parser
-	if withRegex {
-		// If no filter is provided then regex is useless
-		// In this case notify the user
-		if filter == "" {
-			exitWithError("Error! Regex is present but no filter value was provided!")
-		}
-		// Enable regex support for filter of length greater than 1
-		// If regex remains enabled when filter lenght is 1, strange output is given
-		// Also there is no sense in having a regex with length of 1
-		if len(filter) > 1 {
-			// Compile regex expression here to be user later in the parser
-			re, err := regexp.Compile(filter)
-			if err != nil {
-				exitWithError(fmt.Sprintf("Regex parse error: %s", err.Error()))
-			}
-			regex = re
-		}
+	// Compile regex expressions here to be used later in the parser
+	regex, err := compileFilter(filter, withRegex)
+	if err != nil {
+		exitWithError(fmt.Sprintf("Regex parse error: %s", err.Error()))
+	}
+	exReg, err := compileFilter(exclude, withRegex)
+	if err != nil {
+		exitWithError(fmt.Sprintf("Regex parse error: %s", err.Error()))
 	}
 	// Get slice with all extensions
 	exts := strings.Split(ext, ",")
+	batch := BatchMode(opts)
+	// Check if a valid page range was provided
+	from, to, err := parsePageRange(opts.Pages)
+	if err != nil {
+		exitWithError(err.Error())
+	}
+	// Check if a valid stats destination was provided
+	statsOut := opts.Stats
+	if statsOut == "" {
+		statsOut = "stderr"
+	}
+	if !stringInSlice(statsOut, statsOutputs) {
+		exitWithError(fmt.Sprintf("Error! Accepted stats outputs are: %s", strings.Join(statsOutputs, ", ")))
+	}
+	// The merged stream is only browsed with the prompt or printed in batch mode
+	if opts.Merge && (opts.TUI || opts.Output != "" || opts.Export != "" || opts.Dedupe != "") {
+		exitWithError("Error! Option flag -merge cannot be combined with -tui, -output, -export or -dedupe")
+	}
+	// Check if a valid dedupe mode was provided
+	if opts.Dedupe != "" && !stringInSlice(opts.Dedupe, dedupeModes) {
+		exitWithError(fmt.Sprintf("Error! Accepted dedupe modes are: %s", strings.Join(dedupeModes, ", ")))
+	}
+	// Check if a valid bucket size was provided
+	bucket, err := parseBucket(opts.Bucket)
+	if err != nil {
+		exitWithError(err.Error())
+	}
+	// Check if a valid JSON depth was provided
+	if opts.JSONDepth < 0 {
+		exitWithError("Error! Option flag -json-depth cannot be negative")
+	}
+	jsonFmt := &jsonFormatter{depth: opts.JSONDepth, compact: opts.JSONCompact}
+	// Spaces around the fields and empty fields are ignored, like for --group-by
+	for _, field := range strings.Split(opts.JSONExpand, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			jsonFmt.expand = append(jsonFmt.expand, field)
+		}
+	}
+	// Check if a valid redact mode and rules were provided
+	// Rules alone mask the values
+	var red *redactor
+	if opts.Redact != "" || len(opts.RedactRules) > 0 {
+		mode := opts.Redact
+		if mode == "" {
+			mode = "mask"
+		}
+		if red, err = newRedactor(mode, opts.RedactRules); err != nil {
+			exitWithError(err.Error())
+		}
+	}
 
 	return &Parser{
-		path:   path,
-		text:   text,
-		filter: filter,
-		lines:  lines,
-		page:   page,
-		regex:  regex,
-		exts:   exts,
+		path:        path,
+		text:        text,
+		filter:      filter,
+		exclude:     exclude,
+		lines:       lines,
+		page:        page,
+		withRegex:   withRegex,
+		regex:       regex,
+		terms:       filterTerms(filter, regex),
+		jsonFmt:     jsonFmt,
+		exReg:       exReg,
+		exts:        exts,
+		noColor:     noColor,
+		lineNumbers: opts.LineNumbers,
+		tui:         opts.TUI,
+		batch:       batch,
+		from:        from,
+		to:          to,
+		statsOut:    statsOut,
+		grep:        grep,
+		output:      opts.Output,
+		summary:     opts.Summary,
+		export:      opts.Export,
+		context:     opts.Context,
+		compress:    opts.Compress,
+		histogram:   opts.Histogram || bucket > 0,
+		bucket:      bucket,
+		dedupe:      opts.Dedupe,
+		merge:       opts.Merge,
+		redactor:    red,
 	}
 }
 
 // Parse parses the file and shows the output to the user
 func (p *Parser) Parse() {
-	// Channel to receive all file stats
-	sc := make(chan stats)
-	// File stats slice
-	var fs []stats
-	// Get all file paths to traverse
-	paths := p.getPaths()
-	// Get number of possible paths
-	numPaths := len(paths)
-	// This should never happen :)
-	if numPaths == 0 {
-		exitWithError("No valid paths were found!")
+	// Grep compatible output does not need any page offsets
+	if p.grep != grepOff {
+		os.Exit(p.runGrep())
 	}
-	// Set current page to what the parser gave us
-	currentPage := p.page
-	// Count all the lines and provide all page offsets
-	// These offsets help us navigate to any page instantly
-	// We will do this concurrently :)
-	// This is where all the "magic" happens
-	for _, path := range paths {
-		go p.countLines(path, sc)
+	// The merged stream has its own page index
+	if p.merge {
+		p.runMerge()
+		return
 	}
-	// Wait to receive all file offsets
-	for i := 0; i < numPaths; i++ {
-		stat := <-sc
-		if len(stat.offsets) > 0 {
-			fs = append(fs, stat)
-		}
+	// Collect stats for all files
+	all := p.collectStats()
+	// Keep only the files that have something to show
+	fs := visibleStats(all)
+	// Structured output is meant for other tools
+	// so it is printed even if there is nothing to show
+	if p.output != "" {
+		p.runOutput(fs)
+		return
 	}
-	// Readjust with files that have more than 0 pages
-	numPaths = len(fs)
+	// Get number of files with at least 1 page
+	numPaths := len(fs)
 	// If nothing was found exit the program
 	if numPaths == 0 {
 		fmt.Printf("%s\n", info("Sorry. Nothing to show here!"))
 		return
 	}
-	// Start from the first ID
-	currentID := 1
-	// Get first file stat and define it as current stat
-	currentFile := fs[currentID-1]
-	// Define current path
-	currentPath := currentFile.path
-	// Define starting offsets for the first file
-	currentOffsets := currentFile.offsets
+	// Export the matching lines without displaying anything
+	if p.export != "" {
+		total, err := p.exportTo(p.export, fs, p.from, p.to)
+		if err != nil {
+			exitWithError(err.Error())
+		}
+		fmt.Println(info(fmt.Sprintf("%d matching lines were exported to %s", total, p.export)))
+		return
+	}
+	// The full screen navigator takes over from here
+	if p.tui {
+		p.runTUI(fs)
+		return
+	}
+	// In batch mode the pages are printed without asking for navigation
+	if p.batch {
+		p.runBatch(fs)
+		return
+	}
+	// Start from the first file and the page the parser gave us
+	nav := &navigator{p: p, all: all, fs: fs, id: 1, page: p.page}
+	// The histogram is computed once for every filter
+	if p.histogram {
+		nav.hist = p.matchHistogram(fs)
+	}
+	// Load the bookmarks saved in previous sessions
+	nav.loadBookmarks()
 	// Determine total number of pages
-	numPages := len(currentOffsets)
+	numPages := nav.numPages()
 	// The current page cannot be greater than the total number of pages
-	if currentPage > numPages {
+	if nav.page > numPages {
 		fmt.Printf("\n%s\n\n", fail(fmt.Sprintf("Error! Page number cannot be greater than %d", numPages)))
 		return
 	}
-	// Render the table with file stats
-	renderStats(fs, currentID)
-	fmt.Println()
-	// Get the first page output and print it
-	output := p.getFilePage(currentPath, currentOffsets[currentPage-1])
-	// Send output to the console
-	fmt.Println(output)
-	// If we haf only 1 file and no more pages are to be shown stop here
+	// Render the table with file stats and the first page
+	nav.show()
+	// If we had only 1 file and no more pages are to be shown stop here
 	// If means we only have 1 page which we already displayed
 	if numPaths == 1 && numPages == 1 {
 		return
 	}
 	// Show a message telling the user at which page we are right now and prompt to navigate to whatever page
-	fmt.Print(alert(fmt.Sprintf(inputFmt, currentPath, currentPage, numPages)), " ")
+	nav.prompt()
+	// Scan for incoming input in the background
+	// This way the prompt stays responsive while a new filter is applied
+	input := make(chan string)
+	go scanInput(input)
+	// Receives the stats computed for a new filter
+	filtered := make(chan filterResult)
+	for {
+		select {
+		case text, ok := <-input:
+			if !ok {
+				return
+			}
+			// This is how the parser knows where to navigate next
+			cmd, err := extractNavigation(text)
+			if err == nil {
+				switch cmd.action {
+				case actionQuit:
+					return
+				case actionHelp:
+					fmt.Printf("\n%s\n\n", info(helpText))
+				case actionFilter, actionExclude:
+					nav.refilter(cmd, filtered)
+				default:
+					err = nav.run(cmd)
+				}
+			}
+			if err != nil {
+				fmt.Printf("\n%s\n\n", fail(err.Error()))
+			}
+		case res := <-filtered:
+			if err := nav.applyFilter(res); err != nil {
+				fmt.Printf("\n\n%s\n\n", fail(err.Error()))
+			}
+		}
+		nav.prompt()
+	}
+}
+
+// scanInput sends every line typed by the user on the given channel
+// The channel is closed when there is no more input
+func scanInput(ch chan string) {
 	// Start a new input scanner
 	in := bufio.NewScanner(os.Stdin)
 	// Scan for incoming input
 	for in.Scan() {
-		// We only accept page numbers here
-		// This is how the parser knows where to navigate next
-		id, page, err := extractNavigation(in.Text())
-		if err != nil {
-			fmt.Printf("\n%s\n\n", fail("Error! A valid number is required"))
-			fmt.Print(alert(fmt.Sprintf(inputFmt, currentPath, currentPage, numPages)), " ")
-		} else {
-			switch {
-			case page < 1 || page > numPages:
-				fmt.Printf("\n%s\n\n", fail(fmt.Sprintf("Error! Page number must be between 1 and %d", numPages)))
-				fmt.Print(alert(fmt.Sprintf(inputFmt, currentPath, currentPage, numPages)), " ")
-			case id > numPaths:
-				fmt.Printf("\n%s\n\n", fail(fmt.Sprintf("Error! ID number must be between 1 and %d", numPaths)))
-				fmt.Print(alert(fmt.Sprintf(inputFmt, currentPath, currentPage, numPages)), " ")
-			default:
-				// Only if id > 0 then the user wants to change the current file
-				// If the only wants to change the page it sends id=0
-				if id > 0 {
-					currentID = id
-					// Set current file stat value
-					currentFile = fs[currentID-1]
-					// Set current path
-					currentPath = currentFile.path
-					// Set offsets for the first file
-					currentOffsets = currentFile.offsets
-					// Determine total number of pages
-					numPages = len(currentOffsets)
-				}
-				// Set current page
-				currentPage = page
-				fmt.Println()
-				// Render table with files
-				renderStats(fs, currentID)
-				fmt.Println()
-				// Get output for display
-				output := p.getFilePage(currentPath, currentOffsets[currentPage-1])
-				fmt.Printf("\n%s\n", output)
-				fmt.Print(alert(fmt.Sprintf(inputFmt, currentPath, currentPage, numPages)), " ")
-			}
-		}
+		ch <- in.Text()
 	}
 
 	if err := in.Err(); err != nil {
-		log.Fatal("Scanner error:", err)
+		fatal("Scanner error:", err)
 	}
+	close(ch)
 }
 
-// getFilePage gets the output for a new page on the input file
-func (p *Parser) getFilePage(path string, offset int64) string {
+// collectStats counts the lines of every file found in the given path
+// The files keep the order in which they were found on disk
+func (p *Parser) collectStats() []stats {
+	// Get all file paths to traverse
+	paths := p.getPaths()
+	// Get number of possible paths
+	numPaths := len(paths)
+	// This should never happen :)
+	if numPaths == 0 {
+		exitWithError("No valid paths were found!")
+	}
+	// Channel to receive all file stats
+	sc := make(chan stats)
+	// Count all the lines and provide all page offsets
+	// These offsets help us navigate to any page instantly
+	// We will do this concurrently :)
+	// This is where all the "magic" happens
+	for _, path := range paths {
+		go p.countLines(path, sc)
+	}
+	// Wait to receive all file offsets
+	// Stats are indexed by path so the file IDs do not depend
+	// on which goroutine finished first
+	received := make(map[string]stats, numPaths)
+	for i := 0; i < numPaths; i++ {
+		stat := <-sc
+		received[stat.path] = stat
+	}
+	// File stats slice
+	fs := make([]stats, 0, numPaths)
+	for _, path := range paths {
+		fs = append(fs, received[path])
+	}
+
+	return fs
+}
+
+// visibleStats keeps only the file stats that have at least 1 page to show
+func visibleStats(all []stats) []stats {
+	var fs []stats
+	for _, stat := range all {
+		if len(stat.offsets) > 0 {
+			fs = append(fs, stat)
+		}
+	}
+
+	return fs
+}
+
+// firstLine returns the number of the first line of a displayed page
+func (s stats) firstLine(page int) int {
+	offset := s.offsets[page-1]
+	idx := sort.Search(len(s.pages), func(i int) bool { return s.pages[i] >= offset })
+	return s.starts[idx]
+}
+
+// findLine returns the number of the displayed page containing the given line
+// The second value is false when the page is hidden by the filter
+func (s stats) findLine(line int) (int, bool) {
+	// Find the last page starting before the line
+	idx := sort.Search(len(s.starts), func(i int) bool { return s.starts[i] > line }) - 1
+	if idx < 0 {
+		return 0, false
+	}
+	offset := s.pages[idx]
+	page := sort.Search(len(s.offsets), func(i int) bool { return s.offsets[i] >= offset })
+	if page < len(s.offsets) && s.offsets[page] == offset {
+		return page + 1, true
+	}
+	return 0, false
+}
+
+// pageLine is a line of a page as it is displayed
+type pageLine struct {
+	// Line number in the file
+	number int
+	text   string
+	// Number of consecutive duplicates collapsed into this line
+	count int
+}
+
+// readPage reads the lines of the page starting at the given offset
+// The start value is the number of the first line of the page
+// In dedupe mode consecutive duplicates are collapsed into a single line
+func (p *Parser) readPage(path string, offset int64, start int) ([]pageLine, error) {
 	// Open the file
 	f, err := os.Open(path)
 	if err != nil {
-		log.Fatalf("Cannot open file path %s, Error: %v", path, err)
+		return nil, fmt.Errorf("Cannot open file path %s, Error: %v", path, err)
 	}
 	defer f.Close()
 	// Navigate to the given offset
 	// This way we skip the part we don't need
 	// and avoid parsing unnecessary lines
 	if _, err = f.Seek(offset, io.SeekStart); err != nil {
-		log.Fatal("Cannot open file stats:", err)
+		return nil, fmt.Errorf("Cannot open file stats: %v", err)
 	}
 	// Start a new scanner
 	s := bufio.NewScanner(f)
 	// Set a larger buffer just in case
 	s.Buffer(nil, scanBuf)
-	// Current line. We need to keep track
-	// of all current line number to determine
-	// when a page ends
-	var current int
-	// This will hold the final output to be shown to the user
-	// It is reponsable to display only 1 page
-	var output bytes.Buffer
-	// The text that is obtained by the "getOutput" method
-	var text string
+	// The lines of the page
+	var lines []pageLine
+	// The key of the last line, used to find duplicates
+	var lastKey string
 	// Scan the file and extract all lines
 	// Stop when we reach the number of lines per page
 	// that the user specified
-	for s.Scan() {
-		current++
-		// Get the line output and added in the buffer
-		text = p.getOutput(s.Text())
-		fmt.Fprintf(&output, "%s\n", text)
-		if current >= p.lines {
+	for number := start; s.Scan(); number++ {
+		if p.dedupe != "" {
+			key := p.dedupeKey(s.Bytes())
+			if len(lines) > 0 && key == lastKey {
+				lines[len(lines)-1].count++
+				continue
+			}
+			// The page ends where a new run of lines starts
+			if len(lines) >= p.lines {
+				break
+			}
+			lastKey = key
+		}
+		lines = append(lines, pageLine{number: number, text: s.Text(), count: 1})
+		if p.dedupe == "" && len(lines) >= p.lines {
 			break
 		}
 	}
 
 	if err := s.Err(); err != nil {
-		log.Fatal("File page scanner error", err)
+		return nil, fmt.Errorf("File page scanner error: %v", err)
+	}
+
+	return lines, nil
+}
+
+// getFilePage gets the output for a new page on the input file
+// The start value is the number of the first line of the page
+func (p *Parser) getFilePage(path string, offset int64, start int) (string, error) {
+	// This will hold the final output to be shown to the user
+	// It is reponsable to display only 1 page
+	var output bytes.Buffer
+	// Get the output of every line and add it in the buffer
+	// Excluded lines are skipped
+	lines, err := p.readPage(path, offset, start)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range lines {
+		if p.excluded([]byte(line.text)) {
+			continue
+		}
+		fmt.Fprintf(&output, "%s\n", p.numbered(line.number, p.collapsed(p.getOutput(line.number, line.text), line.count)))
 	}
 
-	return output.String()
+	return output.String(), nil
 }
 
 // countLines extracts all line offsets
@@ -315,111 +679,156 @@ func (p *Parser) countLines(path string, ch chan stats) {
 	// Open the file
 	f, err := os.Open(path)
 	if err != nil {
-		log.Fatalf("Cannot open file path %s, Error: %v", path, err)
+		fatalf("Cannot open file path %s, Error: %v", path, err)
 	}
 	defer f.Close()
-	// Get file size the know the position
-	// in the file of the last byte
-	fi, err := f.Stat()
-	if err != nil {
-		log.Fatal("Cannot open file stats:", err)
-	}
-	lastPosition := fi.Size()
 	// Start a new reader
 	r := bufio.NewReader(f)
 	// Here we store all page offsets for all pages
 	var pageOffsets []int64
+	// Here we store the number of the first line for all pages
+	var pageStarts []int
+	// This is the number of lines read so far
+	var numLines int
 	// Here we store page offsets corresponding to filtered text
 	var filterOffsets []int64
-	// The first line
+	// The number of lines on the current page
 	// We need to keep track of every line
 	// to know when a page ends
 	var currentLine int
-	// We store the last offset to help us
-	// calculate the next offset in the slice
-	var lastOffset int64
-	// This is the current offset and is
-	// calculated line by line
+	// This is the position of the reader in the file
 	var offset int64
+	// In dedupe mode this is the key of the previous line
+	// Lines with the same key are displayed as 1 line
+	var lastKey string
 	// This is used to know if page has been hit (matched)
 	// by a filter provided by the user
 	var pageHit bool
 	// This is used to count the number of matches
 	var matches int
+	// This is used to count the number of matches on the current page
+	var pageMatches int
+	// Here we store the number of matches for all pages
+	var pageHits []int
+	// Here we store the number of matches for filtered pages
+	var filterHits []int
+	// endPage stores the hits of the current page
+	endPage := func() {
+		// If we have a page hit (from filtered input)
+		// Add it to the filter offsets
+		if pageHit {
+			filterOffsets = append(filterOffsets, pageOffsets[len(pageOffsets)-1])
+			filterHits = append(filterHits, pageMatches)
+		}
+		pageHits = append(pageHits, pageMatches)
+	}
 	// We start by adding the first page offset which is 0
 	pageOffsets = append(pageOffsets, offset)
+	pageStarts = append(pageStarts, 1)
 	// Read all lines one by one
 	for {
-		currentLine++
 		// Read the input file line by line
 		// This may take a while depending on the file size
 		// This is the most time consuming portion of the app
 		line, err := r.ReadBytes('\n')
-		// If we have at least 1 line hit it means we have a page hit
-		// We also keep track of the total number of hits
-		numHits := p.lineHits(line)
-		if numHits > 0 {
-			pageHit = true
-			matches += numHits
-		}
-		// Compute the current offset
-		offset += int64(len(line))
-		if currentLine == p.lines {
-			// If we have reached the end of the page
-			// Extract the last page offset
-			lastOffset = pageOffsets[len(pageOffsets)-1]
-			// If we have a page hit (from filtered input)
-			// Add it to the filter offsets
-			if pageHit {
-				filterOffsets = append(filterOffsets, pageOffsets[len(pageOffsets)-1])
+		if len(line) > 0 {
+			// Duplicates are collapsed into the previous line
+			// so they take no room on the page and are not counted again
+			duplicate := false
+			if p.dedupe != "" {
+				key := p.dedupeKey(line)
+				duplicate = numLines > 0 && key == lastKey
+				lastKey = key
 			}
-			// Add it to the current offset
-			// Append this offset to the end of our slice
-			offset += lastOffset
-			if offset < lastPosition {
-				pageOffsets = append(pageOffsets, offset)
+			if !duplicate {
+				// If we have reached the end of the page
+				// a new page starts with this line
+				if currentLine == p.lines {
+					endPage()
+					pageOffsets = append(pageOffsets, offset)
+					pageStarts = append(pageStarts, numLines+1)
+					// Reset all counters and also the page hit
+					currentLine = 0
+					pageHit = false
+					pageMatches = 0
+				}
+				currentLine++
+				// If we have at least 1 line hit it means we have a page hit
+				// We also keep track of the total number of hits
+				if numHits := p.lineHits(line); numHits > 0 {
+					pageHit = true
+					matches += numHits
+					pageMatches += numHits
+				}
+				// If only an exclusion was provided
+				// every line that is not excluded is a page hit
+				if p.filter == "" && !p.excluded(line) {
+					pageHit = true
+				}
 			}
-			// Reset all counters and also the page hit
-			currentLine = 0
-			offset = 0
-			pageHit = false
+			// Compute the current offset
+			offset += int64(len(line))
+			numLines++
 		}
 
 		switch {
 		case err == io.EOF:
 			// If we reached the end of file
-			// we must take into account also the last lines
-			// which were not caught by the page offsets
-			if currentLine < p.lines && pageHit {
-				filterOffsets = append(filterOffsets, pageOffsets[len(pageOffsets)-1])
-			}
+			// we must take into account also the last page
+			endPage()
 			// This will hold the offsets to be sent on the channel
 			var finalOffsets []int64
+			var finalHits []int
 			// If the input was filtered return filter page offsets
 			// Otherwise return normal page offsets
-			if p.filter != "" {
+			if p.filtered() {
 				finalOffsets = filterOffsets
+				finalHits = filterHits
 			} else {
 				finalOffsets = pageOffsets
+				finalHits = pageHits
 			}
 			ch <- stats{
 				path:    path,
+				pages:   pageOffsets,
+				starts:  pageStarts,
+				lines:   numLines,
 				offsets: finalOffsets,
+				hits:    finalHits,
 				matches: matches,
 			}
 			// Exit the function to avoid goroutine leak
 			return
 
 		case err != nil:
-			log.Fatal("Count lines error:", err)
+			fatal("Count lines error:", err)
 		}
 	}
 }
 
+// dedupeKey returns the key used to find consecutive duplicates
+// Lines with the same key are collapsed in dedupe mode
+func (p *Parser) dedupeKey(line []byte) string {
+	line = bytes.TrimRight(line, "\r\n")
+	if p.dedupe == "masked" {
+		return maskLine(string(line))
+	}
+	return string(line)
+}
+
+// collapsed adds the number of duplicates to a line that stands for more lines
+func (p *Parser) collapsed(text string, count int) string {
+	if count <= 1 {
+		return text
+	}
+	return fmt.Sprintf("%s %s", text, alert(fmt.Sprintf("(x%d)", count)))
+}
+
 // lineHits determines the number of line matches for a given filter
 func (p *Parser) lineHits(line []byte) int {
 	// If no filter was provided then we do not care about this
-	if p.filter == "" {
+	// Excluded lines are never counted
+	if p.filter == "" || p.excluded(line) {
 		return 0
 	}
 	// If regex was enbled, search by regex
@@ -432,34 +841,87 @@ func (p *Parser) lineHits(line []byte) int {
 	return bytes.Count(line, []byte(p.filter))
 }
 
-// getOutput computes the final output
-func (p *Parser) getOutput(text string) string {
+// getOutput computes the final output of a line
+// The line number tells the headers of a diff apart from its removed lines
+func (p *Parser) getOutput(number int, text string) string {
+	// Sensitive values are hidden before anything else
+	text = p.redact(text)
+	// Lines of a diff are colored by their kind
+	if p.diff {
+		return colorDiff(text, p.highlight(text), number <= diffHeaderLines)
+	}
+	return p.highlight(text)
+}
+
+// highlight formats JSON, XML and YAML and colors their tokens, the log levels and the matches of the filter
+func (p *Parser) highlight(text string) string {
+	var spans []colorSpan
 	// Format input as JSON if needed
-	if p.text == "json" {
-		jsonMatches := jsonReg.FindAllString(text, -1)
-		for _, m := range jsonMatches {
-			text = strings.Replace(text, m, formatJSON(m), -1)
+	switch p.text {
+	case "json":
+		var b strings.Builder
+		last := 0
+		for _, m := range findJSON(text) {
+			b.WriteString(text[last:m[0]])
+			// Malformed JSON is left as it is
+			formatted, ok := p.jsonFmt.format(text[m[0]:m[1]])
+			if ok {
+				spans = append(spans, jsonSpans(formatted, b.Len())...)
+			}
+			b.WriteString(formatted)
+			last = m[1]
 		}
-	}
-	// If no filter was provided give the text as it is
-	if p.filter == "" {
+		b.WriteString(text[last:])
+		text = b.String()
+	case "xml":
+		// Malformed XML is left as it is but its tags are still colored
+		var b strings.Builder
+		last := 0
+		for _, m := range findXML(text) {
+			b.WriteString(text[last:m[0]])
+			formatted, _ := formatXML(text[m[0]:m[1]])
+			b.WriteString(formatted)
+			last = m[1]
+		}
+		b.WriteString(text[last:])
+		text = b.String()
+		spans = xmlSpans(text)
+	case "yaml":
+		var inDoc []bool
+		text, inDoc = embedYAML(text)
+		spans = yamlSpans(text, inDoc)
+	}
+	// Without colors there is nothing else to do
+	if color.NoColor {
 		return text
 	}
-	// For regex/normal search highlight filtered input text
-	if p.regex != nil {
-		// Find all matches
-		matches := p.regex.FindAllString(text, -1)
-		if matches == nil {
-			return text
-		}
-		// Highlight every match in given input
-		for _, m := range matches {
-			text = strings.Replace(text, m, success(m), -1)
+	spans = append(spans, levelSpans(text)...)
+	// Matches of the filter come last so they are colored above everything else
+	match := currentTheme.get("match")
+	switch {
+	case p.filter == "":
+	case p.regex != nil:
+		spans = append(spans, p.termSpans(text)...)
+	default:
+		for i := 0; ; {
+			j := strings.Index(text[i:], p.filter)
+			if j < 0 {
+				break
+			}
+			i += j
+			spans = append(spans, colorSpan{i, i + len(p.filter), match})
+			i += len(p.filter)
 		}
-		return text
 	}
+	return paintSpans(text, spans)
+}
 
-	return strings.Replace(text, p.filter, success(p.filter), -1)
+// numbered prefixes the output of a line with its number if the user asks for it
+func (p *Parser) numbered(line int, text string) string {
+	if !p.lineNumbers {
+		return text
+	}
+	return fmt.Sprintf("%s %s", info(fmt.Sprintf("%6d:", line)), text)
 }
 
 // getPaths retrieves file paths for a given root
@@ -469,7 +931,7 @@ func (p *Parser) getPaths() []string {
 	// Walk the file/directory
 	err := filepath.Walk(p.path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			log.Fatalf("File walk error for: %s", path)
+			fatalf("File walk error for: %s", path)
 		}
 		// If the root path is a file
 		// just append use the path without verifying extentions
@@ -488,51 +950,18 @@ func (p *Parser) getPaths() []string {
 		return nil
 	})
 	if err != nil {
-		log.Fatal("Directory walk error: ", err)
+		fatal("Directory walk error: ", err)
 	}
 
 	return paths
 }
 
-// extractNavigation fetches navigation details (file id and page number)
-func extractNavigation(s string) (int, int, error) {
-	nums := strings.Split(s, ",")
-	if len(nums) > 2 {
-		exitWithError("More than 2 numbers provided")
-	}
-	// Only page number counts in this situation
-	// The user wants to use the current file and only change the page
-	// So we return id=0 as a convention here
-	if len(nums) == 1 {
-		page, err := strconv.Atoi(strings.Trim(nums[0], " "))
-		if err != nil {
-			return 0, 0, err
-		}
-		return 0, page, nil
-	}
-	// The user wants to change the file and page implicitly
-	id, err := strconv.Atoi(strings.Trim(nums[0], " "))
-	if err != nil {
-		return 0, 0, err
-	}
-	page, err := strconv.Atoi(strings.Trim(nums[1], " "))
-	if err != nil {
-		return 0, 0, err
-	}
-	return id, page, nil
-}
-
 // renderStats Displays the current stats for all files
-func renderStats(fs []stats, id int) {
+func renderStats(w io.Writer, fs []stats, id int) {
 	// Set table options
-	table := tablewriter.NewWriter(os.Stdout)
-
-	table.SetRowLine(true)
-	table.SetCenterSeparator("+")
-	table.SetColumnSeparator("|")
-	table.SetRowSeparator("-")
+	table := newTable(w)
 	// Define table header
-	table.SetHeader([]string{"File ID", "File Path", "Number of Pages", "Number of Matches", "Current"})
+	setTableHeader(table, []string{"File ID", "File Path", "Number of Pages", "Number of Matches", "Current"})
 	// Compute the table
 	var current string
 	for k, v := range fs {
@@ -550,7 +979,7 @@ func renderStats(fs []stats, id int) {
 		}
 		table.Append(row)
 	}
-	fmt.Println(info(fmt.Sprintf("Current File ID is %d", id)))
+	fmt.Fprintln(w, info(fmt.Sprintf("Current File ID is %d", id)))
 	// Render the table
 	table.Render()
 }
@@ -0,0 +1,203 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Token replacing the parts of a pattern that vary between lines
+const wildcard = "<*>"
+
+// Maximum length of the patterns and examples shown in the table
+const maxPatternWidth = 100
+
+// mask replaces the variable parts of a line matching a regex by a token
+type mask struct {
+	reg   *regexp.Regexp
+	token string
+}
+
+// Masks shared by the patterns and the diffs
+var (
+	// ISO 8601 timestamps, e.g. 2019-12-10T15:04:05.123Z
+	isoTimeMask = mask{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`), "<TIME>"}
+	// Nginx and Apache timestamps, e.g. 10/Dec/2019:15:04:05 +0200
+	nginxTimeMask = mask{regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2}(?: [+-]\d{4})?`), "<TIME>"}
+	// Bare times, e.g. 15:04:05,123
+	clockTimeMask = mask{regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}(?:[.,]\d+)?\b`), "<TIME>"}
+	uuidMask      = mask{regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`), "<UUID>"}
+)
+
+// Masks applied to every line before lines are compared
+// The most specific ones come first so numbers inside them are not masked on their own
+var masks = []mask{
+	isoTimeMask,
+	nginxTimeMask,
+	clockTimeMask,
+	uuidMask,
+	{regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`), "<IP>"},
+	{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b|\b[0-9a-fA-F]{8,}\b`), "<HEX>"},
+	{regexp.MustCompile(`[-+]?\b\d+(?:\.\d+)?\b`), "<NUM>"},
+}
+
+// maskLine replaces timestamps, UUIDs, IPs, hex IDs and numbers by tokens
+// Lines that only differ by these values have the same mask
+func maskLine(line string) string {
+	for _, m := range masks {
+		line = m.reg.ReplaceAllString(line, m.token)
+	}
+	return line
+}
+
+// pattern is a group of lines with the same template
+type pattern struct {
+	tokens []string
+	count  int
+	// The first line that created the pattern
+	example string
+}
+
+// String returns the template of the pattern
+func (pt *pattern) String() string {
+	return strings.Join(pt.tokens, " ")
+}
+
+// similarity returns the share of tokens that are the same in the pattern and the line
+func (pt *pattern) similarity(tokens []string) float64 {
+	var same int
+	for i, t := range tokens {
+		if pt.tokens[i] == t {
+			same++
+		}
+	}
+	return float64(same) / float64(len(tokens))
+}
+
+// merge replaces the tokens that differ from the line by a wildcard
+func (pt *pattern) merge(tokens []string) {
+	for i, t := range tokens {
+		if pt.tokens[i] != t {
+			pt.tokens[i] = wildcard
+		}
+	}
+}
+
+// clusterer groups lines into patterns, just like the Drain algorithm does
+// Lines are first grouped by number of tokens and first token
+// Then every line joins the most similar pattern of its group
+type clusterer struct {
+	// Minimum share of equal tokens for a line to join a pattern
+	threshold float64
+	groups    map[string][]*pattern
+	// All patterns in the order they were found
+	patterns []*pattern
+	// Number of lines added
+	lines int
+}
+
+// newClusterer returns an empty clusterer
+func newClusterer(threshold float64) *clusterer {
+	return &clusterer{threshold: threshold, groups: make(map[string][]*pattern)}
+}
+
+// add puts a line in the most similar pattern or creates a new one
+func (c *clusterer) add(line string) {
+	tokens := strings.Fields(maskLine(line))
+	if len(tokens) == 0 {
+		return
+	}
+	c.lines++
+	// The first token makes a good group key unless it varies
+	first := tokens[0]
+	if strings.ContainsAny(first, "0123456789<") {
+		first = wildcard
+	}
+	key := strconv.Itoa(len(tokens)) + " " + first
+	var best *pattern
+	var bestSim float64
+	for _, pt := range c.groups[key] {
+		if sim := pt.similarity(tokens); sim > bestSim {
+			best, bestSim = pt, sim
+		}
+	}
+	if best != nil && bestSim >= c.threshold {
+		best.merge(tokens)
+		best.count++
+		return
+	}
+	pt := &pattern{tokens: tokens, count: 1, example: line}
+	c.groups[key] = append(c.groups[key], pt)
+	c.patterns = append(c.patterns, pt)
+}
+
+// top returns the n patterns with the most lines
+// Patterns with the same number of lines keep the order they were found in
+func (c *clusterer) top(n int) []*pattern {
+	patterns := make([]*pattern, len(c.patterns))
+	copy(patterns, c.patterns)
+	sort.SliceStable(patterns, func(i, j int) bool { return patterns[i].count > patterns[j].count })
+	if n > 0 && len(patterns) > n {
+		patterns = patterns[:n]
+	}
+	return patterns
+}
+
+// Patterns prints the most common message patterns of the matching lines
+// Similarity is the minimum share of equal tokens for lines to share a pattern
+func (p *Parser) Patterns(top int, similarity float64) {
+	if top < 0 {
+		exitWithError("Error! Option flag -top cannot be negative")
+	}
+	if similarity <= 0 || similarity > 1 {
+		exitWithError("Error! Option flag -similarity must be between 0 and 1")
+	}
+	fs := visibleStats(p.collectStats())
+	c := newClusterer(similarity)
+	for _, stat := range fs {
+		err := p.eachRecord(stat, func(r record) bool {
+			c.add(r.Text)
+			return true
+		})
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if c.lines == 0 {
+		fmt.Printf("%s\n", info("Sorry. Nothing to show here!"))
+		return
+	}
+	renderPatterns(os.Stdout, c, top)
+}
+
+// renderPatterns prints the top patterns as a table
+func renderPatterns(w io.Writer, c *clusterer, top int) {
+	table := newTable(w)
+	// Patterns are easier to read on a single line
+	table.SetAutoWrapText(false)
+	setTableHeader(table, []string{"Rank", "Count", "Share", "Pattern", "Example"})
+	for i, pt := range c.top(top) {
+		table.Append([]string{
+			strconv.Itoa(i + 1),
+			strconv.Itoa(pt.count),
+			fmt.Sprintf("%.1f%%", float64(pt.count)*100/float64(c.lines)),
+			shorten(pt.String(), maxPatternWidth),
+			shorten(pt.example, maxPatternWidth),
+		})
+	}
+	fmt.Fprintln(w, info(fmt.Sprintf("%d lines grouped in %d patterns", c.lines, len(c.patterns))))
+	table.Render()
+}
+
+// shorten cuts a text longer than the given number of characters
+func shorten(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
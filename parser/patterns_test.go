@@ -0,0 +1,55 @@
+package parser
+
+import "testing"
+
+// TestMaskLine tests if the variable parts of a line are masked
+func TestMaskLine(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"2019-12-10T15:04:05Z ERROR upstream 502", "<TIME> ERROR upstream <NUM>"},
+		{"Dec 10 15:04:05 host sshd[123]: ok", "Dec <NUM> <TIME> host sshd[<NUM>]: ok"},
+		{"request 3f2b8c1e-9a4d-4e2f-8b1a-0c9d8e7f6a5b done", "request <UUID> done"},
+		{"from 192.168.1.20:8080 in 1.5 s", "from <IP> in <NUM> s"},
+		{"object 0x7ffd42 hash deadbeef01", "object <HEX> hash <HEX>"},
+		{"no variable parts", "no variable parts"},
+	}
+	for _, tt := range tests {
+		if got := maskLine(tt.in); got != tt.want {
+			t.Errorf("maskLine(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestClusterer tests if similar lines share a pattern
+func TestClusterer(t *testing.T) {
+	c := newClusterer(0.5)
+	for _, line := range []string{
+		"user bob logged in",
+		"db timeout on shard alpha",
+		"user alice logged in",
+		"db timeout on shard beta",
+		"user carol logged in",
+		"",
+		"shutting down",
+	} {
+		c.add(line)
+	}
+	if c.lines != 6 {
+		t.Errorf("got %d lines, want 6", c.lines)
+	}
+	top := c.top(2)
+	if len(top) != 2 {
+		t.Fatalf("got %d patterns, want 2", len(top))
+	}
+	if got := top[0].String(); got != "user <*> logged in" || top[0].count != 3 {
+		t.Errorf("got pattern %q with %d lines", got, top[0].count)
+	}
+	if got := top[1].String(); got != "db timeout on shard <*>" || top[1].example != "db timeout on shard alpha" {
+		t.Errorf("got pattern %q with example %q", got, top[1].example)
+	}
+	if n := len(c.top(0)); n != 3 {
+		t.Errorf("got %d patterns, want 3", n)
+	}
+}
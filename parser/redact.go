@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Accepted ways to hide sensitive values
+// Mask replaces a value by its kind, hash by its kind and a stable hash, drop removes it
+var redactModes = []string{
+	"mask",
+	"hash",
+	"drop",
+}
+
+// Name used for the values found by user defined rules
+const redactRuleName = "REDACTED"
+
+// detector finds a kind of sensitive value
+// When the regex has a group called value only that group is hidden
+type detector struct {
+	name string
+	reg  *regexp.Regexp
+	// Optional check to drop false positives
+	valid func(value string) bool
+}
+
+// Built-in detectors, in the order they are applied
+// Secrets come first so their parts are not taken for other values
+var detectors = []detector{
+	{name: "TOKEN", reg: regexp.MustCompile(`(?i)\bbearer\s+(?P<value>[A-Za-z0-9\-._~+/]+=*)`)},
+	{name: "AWS_SECRET", reg: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[=:]\s*["']?(?P<value>[A-Za-z0-9/+=]{40})`)},
+	{name: "AWS_KEY", reg: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA)[0-9A-Z]{16}\b`)},
+	{name: "EMAIL", reg: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	{name: "CARD", reg: regexp.MustCompile(`\b[2-6](?:[ -]?\d){12,18}\b`), valid: validCard},
+	{name: "IP", reg: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)},
+	{name: "IP", reg: regexp.MustCompile(`(?:^|[^\w:])(?P<value>[0-9A-Fa-f:]*:[0-9A-Fa-f:]*:[0-9A-Fa-f]*)`), valid: validIPv6},
+}
+
+// redactor hides sensitive values in the lines
+type redactor struct {
+	mode      string
+	detectors []detector
+}
+
+// newRedactor returns a redactor using the built-in detectors and the user rules
+func newRedactor(mode string, rules []string) (*redactor, error) {
+	if !stringInSlice(mode, redactModes) {
+		return nil, fmt.Errorf("Error! Accepted redact modes are: %s", strings.Join(redactModes, ", "))
+	}
+	r := &redactor{mode: mode, detectors: append([]detector{}, detectors...)}
+	for _, rule := range rules {
+		reg, err := regexp.Compile(rule)
+		if err != nil {
+			return nil, fmt.Errorf("Error! Invalid redact rule %q: %v", rule, err)
+		}
+		r.detectors = append(r.detectors, detector{name: redactRuleName, reg: reg})
+	}
+	return r, nil
+}
+
+// redact hides every sensitive value of a line
+func (r *redactor) redact(line string) string {
+	for _, d := range r.detectors {
+		line = r.apply(d, line)
+	}
+	return line
+}
+
+// apply hides the values found by a detector
+func (r *redactor) apply(d detector, line string) string {
+	matches := d.reg.FindAllStringSubmatchIndex(line, -1)
+	if matches == nil {
+		return line
+	}
+	group := valueGroup(d.reg)
+	var b strings.Builder
+	var last int
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if group > 0 {
+			start, end = m[2*group], m[2*group+1]
+		}
+		if start < 0 || start == end {
+			continue
+		}
+		value := line[start:end]
+		if d.valid != nil && !d.valid(value) {
+			continue
+		}
+		b.WriteString(line[last:start])
+		b.WriteString(r.replacement(d.name, value))
+		last = end
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// valueGroup returns the index of the group called value or 0 when there is none
+func valueGroup(reg *regexp.Regexp) int {
+	for i, name := range reg.SubexpNames() {
+		if name == "value" {
+			return i
+		}
+	}
+	return 0
+}
+
+// replacement returns the text shown instead of a value
+// Hashes are stable so the same value can still be followed across lines
+func (r *redactor) replacement(name, value string) string {
+	switch r.mode {
+	case "hash":
+		sum := sha256.Sum256([]byte(value))
+		return fmt.Sprintf("<%s:%s>", name, hex.EncodeToString(sum[:4]))
+	case "drop":
+		return ""
+	}
+	return "<" + name + ">"
+}
+
+// validCard checks card numbers with the Luhn algorithm
+func validCard(value string) bool {
+	var sum, digits int
+	for i := len(value) - 1; i >= 0; i-- {
+		c := value[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && digits <= 19 && sum%10 == 0
+}
+
+// validIPv6 drops the values with colons that are not IPv6 addresses, like times
+func validIPv6(value string) bool {
+	return net.ParseIP(value) != nil
+}
+
+// redact hides the sensitive values of a line if the user asks for it
+func (p *Parser) redact(line string) string {
+	if p.redactor == nil {
+		return line
+	}
+	return p.redactor.redact(line)
+}
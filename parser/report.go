@@ -0,0 +1,291 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportFile holds the data of a file shown in the HTML report
+type reportFile struct {
+	ID      int
+	Path    string
+	Pages   int
+	Lines   int
+	Matches int
+	// Width of the match count bar (percent)
+	Bar int
+	// Matching lines converted to HTML
+	Records []reportLine
+	// Number of matching lines left out of the report
+	Hidden int
+}
+
+// reportLine is a matching line shown in the HTML report
+type reportLine struct {
+	Number int
+	HTML   template.HTML
+}
+
+// reportBucket is a bar of the timeline histogram
+type reportBucket struct {
+	Label  string
+	Count  int
+	Height int
+}
+
+// reportData is everything the report template needs
+type reportData struct {
+	Generated  string
+	Path       string
+	Filter     string
+	Exclude    string
+	Files      []reportFile
+	Total      int
+	Timeline   []reportBucket
+	First      string
+	Last       string
+	BucketSize string
+	Untimed    int
+	// Tells that the bucket size given by the user was replaced
+	Notice string
+}
+
+// Report writes a self contained HTML report with the stats and the matching lines
+// At most maxLines matching lines are included for every file (0 means no limit)
+func (p *Parser) Report(out string, maxLines int) error {
+	all := p.collectStats()
+	fs := visibleStats(all)
+	// Highlights are converted to CSS classes so they must be colored
+	// unless the user explicitly disabled colors
+	// Only the basic colors have CSS classes
+	if !p.noColor {
+		defer forceBasicColors()()
+	}
+
+	data := reportData{
+		Generated: time.Now().Format(time.RFC1123),
+		Path:      p.path,
+		Filter:    p.filter,
+		Exclude:   p.exclude,
+	}
+	counter := newTimeCounter()
+	maxMatches := 0
+	for _, stat := range fs {
+		if stat.matches > maxMatches {
+			maxMatches = stat.matches
+		}
+	}
+	for i, stat := range fs {
+		rf := reportFile{ID: i + 1, Path: stat.path, Pages: len(stat.offsets), Lines: stat.lines, Matches: stat.matches}
+		if maxMatches > 0 {
+			rf.Bar = stat.matches * 100 / maxMatches
+		}
+		err := p.eachRecord(stat, func(r record) bool {
+			n := len(r.Matches)
+			if n == 0 {
+				n = 1
+			}
+			counter.add(r.Text, n)
+			data.Total++
+			if maxLines > 0 && len(rf.Records) >= maxLines {
+				rf.Hidden++
+				return true
+			}
+			rf.Records = append(rf.Records, reportLine{
+				Number: r.Line,
+				HTML:   template.HTML(ansiToHTML(p.getOutput(r.Line, r.Text))),
+			})
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		data.Files = append(data.Files, rf)
+	}
+	if h := counter.histogram(p.bucket, maxBuckets); h != nil {
+		data.BucketSize = formatBucket(h.size)
+		data.Untimed = h.untimed
+		if h.requested > 0 {
+			data.Notice = h.notice()
+		}
+		max := h.max()
+		for i, c := range h.counts {
+			data.Timeline = append(data.Timeline, reportBucket{
+				Label:  h.bucketStart(i).Format("2006-01-02 15:04:05"),
+				Count:  c,
+				Height: c * 100 / max,
+			})
+		}
+		data.First = data.Timeline[0].Label
+		data.Last = data.Timeline[len(data.Timeline)-1].Label
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("Error! Cannot create report file: %v", err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := reportTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("Error! Cannot write report: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("Error! Cannot write report: %v", err)
+	}
+	fmt.Println(info(fmt.Sprintf("Report with %d matching lines was written to %s", data.Total, out)))
+	return nil
+}
+
+// ansiToHTML converts text colored with ANSI escape sequences into HTML
+// Every color attribute becomes a CSS class (e.g. fg-92, bg-41, bold)
+func ansiToHTML(s string) string {
+	var b strings.Builder
+	// Classes of the currently open span
+	var classes []string
+	open := false
+	for len(s) > 0 {
+		loc := ansiReg.FindStringIndex(s)
+		if loc == nil {
+			b.WriteString(html.EscapeString(s))
+			break
+		}
+		b.WriteString(html.EscapeString(s[:loc[0]]))
+		seq := s[loc[0]:loc[1]]
+		s = s[loc[1]:]
+		// Only SGR sequences (ending with m) change the style
+		if !strings.HasSuffix(seq, "m") {
+			continue
+		}
+		classes = applySGR(classes, strings.Split(seq[2:len(seq)-1], ";"))
+		if open {
+			b.WriteString("</span>")
+			open = false
+		}
+		if len(classes) > 0 {
+			fmt.Fprintf(&b, `<span class="%s">`, strings.Join(classes, " "))
+			open = true
+		}
+	}
+	if open {
+		b.WriteString("</span>")
+	}
+	return b.String()
+}
+
+// applySGR updates the CSS classes with the given SGR parameters
+func applySGR(classes []string, params []string) []string {
+	for i := 0; i < len(params); i++ {
+		code, err := strconv.Atoi(params[i])
+		if err != nil || code == 0 {
+			classes = nil
+			continue
+		}
+		switch {
+		case code == 1:
+			classes = setClass(classes, "bold", "bold")
+		case code == 3:
+			classes = setClass(classes, "italic", "italic")
+		case code == 4:
+			classes = setClass(classes, "underline", "underline")
+		case code == 7:
+			classes = setClass(classes, "reverse", "reverse")
+		case (code == 38 || code == 48) && i+2 < len(params) && params[i+1] == "5":
+			// 256 colors and true colors cannot be listed in the style sheet so they are skipped
+			// The report only uses the basic colors anyway
+			i += 2
+		case (code == 38 || code == 48) && i+4 < len(params) && params[i+1] == "2":
+			i += 4
+		case code >= 30 && code <= 37, code >= 90 && code <= 97:
+			classes = setClass(classes, "fg-", fmt.Sprintf("fg-%d", code))
+		case code >= 40 && code <= 47, code >= 100 && code <= 107:
+			classes = setClass(classes, "bg-", fmt.Sprintf("bg-%d", code))
+		}
+	}
+	return classes
+}
+
+// setClass replaces the classes starting with the given prefix by a new class
+func setClass(classes []string, prefix, class string) []string {
+	res := classes[:0:0]
+	for _, c := range classes {
+		if !strings.HasPrefix(c, prefix) {
+			res = append(res, c)
+		}
+	}
+	return append(res, class)
+}
+
+// CSS classes used by ansiToHTML for the colored text
+// Text is expected on a dark background
+const ansiStyles = `.bold { font-weight: bold; } .italic { font-style: italic; } .underline { text-decoration: underline; }
+.reverse { background: #ddd; color: #1e1e1e; }
+.fg-30 { color: #555; } .fg-31 { color: #e06c75; } .fg-32 { color: #98c379; } .fg-33 { color: #e5c07b; }
+.fg-34 { color: #61afef; } .fg-35 { color: #c678dd; } .fg-36 { color: #56b6c2; } .fg-37 { color: #dcdfe4; }
+.fg-90 { color: #7f848e; } .fg-91 { color: #ff7b86; } .fg-92 { color: #b5e890; } .fg-93 { color: #ffd580; }
+.fg-94 { color: #82c4ff; } .fg-95 { color: #e59cff; } .fg-96 { color: #7fe3ef; } .fg-97 { color: #ffffff; }
+.bg-40 { background: #555; } .bg-41 { background: #c0392b; } .bg-42 { background: #27ae60; } .bg-43 { background: #d4a017; }
+.bg-44 { background: #2e6db4; } .bg-45 { background: #8e44ad; } .bg-46 { background: #16a085; } .bg-47 { background: #bbb; }
+.bg-100 { background: #777; } .bg-101 { background: #e74c3c; } .bg-102 { background: #2ecc71; } .bg-103 { background: #f1c40f; }
+.bg-104 { background: #3498db; } .bg-105 { background: #9b59b6; } .bg-106 { background: #1abc9c; } .bg-107 { background: #eee; color: #222; }
+`
+
+// The HTML report template
+// Everything (styles included) is inlined so the file can be shared as it is
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Logy report for {{.Path}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #ddd; padding-bottom: .3em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: .3em .8em; text-align: left; }
+th { background: #f4f4f4; }
+td.num { text-align: right; }
+.meta { color: #666; }
+.bar { background: #3a7; height: 1em; min-width: 1px; }
+.timeline { display: flex; align-items: flex-end; height: 160px; border-bottom: 1px solid #999; gap: 1px; }
+.timeline div { flex: 1; background: #3a7; min-height: 1px; }
+.axis { display: flex; justify-content: space-between; color: #666; font-size: .8em; }
+pre { background: #1e1e1e; color: #ddd; padding: 1em; overflow-x: auto; line-height: 1.4; }
+.ln { color: #888; user-select: none; }
+` + ansiStyles + `</style>
+</head>
+<body>
+<h1>Logy report for {{.Path}}</h1>
+<p class="meta">Generated on {{.Generated}}{{if .Filter}} &middot; Filter: <code>{{.Filter}}</code>{{end}}{{if .Exclude}} &middot; Exclusion: <code>{{.Exclude}}</code>{{end}} &middot; {{.Total}} matching lines</p>
+
+<h2>Files</h2>
+{{if .Files}}
+<table>
+<tr><th>File ID</th><th>File Path</th><th>Number of Pages</th><th>Number of Lines</th><th>Number of Matches</th><th></th></tr>
+{{range .Files}}<tr><td class="num">{{.ID}}</td><td><a href="#file-{{.ID}}">{{.Path}}</a></td><td class="num">{{.Pages}}</td><td class="num">{{.Lines}}</td><td class="num">{{.Matches}}</td><td style="width: 200px"><div class="bar" style="width: {{.Bar}}%"></div></td></tr>
+{{end}}</table>
+{{else}}
+<p>Sorry. Nothing to show here!</p>
+{{end}}
+
+{{if .Timeline}}
+<h2>Timeline</h2>
+<p class="meta">Matches per {{.BucketSize}}{{if .Untimed}} &middot; {{.Untimed}} matches without a timestamp are not shown{{end}}{{if .Notice}} &middot; {{.Notice}}{{end}}</p>
+<div class="timeline">{{range .Timeline}}<div style="height: {{.Height}}%" title="{{.Label}}: {{.Count}}"></div>{{end}}</div>
+<div class="axis"><span>{{.First}}</span><span>{{.Last}}</span></div>
+{{end}}
+
+{{range .Files}}
+<h2 id="file-{{.ID}}">{{.Path}}</h2>
+<pre>{{range .Records}}<span class="ln">{{printf "%6d" .Number}}</span>  {{.HTML}}
+{{end}}</pre>
+{{if .Hidden}}<p class="meta">{{.Hidden}} more matching lines are not shown</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
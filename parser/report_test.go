@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestANSIToHTML tests if colored text is converted to HTML with CSS classes
+func TestANSIToHTML(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain <text> & more", "plain &lt;text&gt; &amp; more"},
+		{"a \x1b[92;1mERROR\x1b[0m b", `a <span class="fg-92 bold">ERROR</span> b`},
+		{"\x1b[31mred\x1b[32mgreen\x1b[0m", `<span class="fg-31">red</span><span class="fg-32">green</span>`},
+		// Only the basic colors have CSS classes
+		{"\x1b[38;5;208morange", "orange"},
+		{"\x1b[1;38;2;255;0;0mbold", `<span class="bold">bold</span>`},
+		{"\x1b[1mbold \x1b[4munder\x1b[0m", `<span class="bold">bold </span><span class="bold underline">under</span>`},
+	}
+	for _, tt := range tests {
+		if got := ansiToHTML(tt.in); got != tt.want {
+			t.Errorf("ansiToHTML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestReport tests the stats table, the escaping of the lines and the timeline of the HTML report
+func TestReport(t *testing.T) {
+	path, cleanup := writeTestFile(t, "2020-01-01T10:00:00Z ERROR a < b & c\n"+
+		"2020-01-01T10:05:00Z INFO ok\n"+
+		"2020-01-01T10:10:00Z ERROR <script>\n")
+	defer cleanup()
+	p := New(path, Options{Text: "plain", Filter: "ERROR", Lines: 10, Page: 1, NoColor: true})
+	out := filepath.Join(filepath.Dir(path), "report.html")
+	if err := p.Report(out, 0); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := string(data)
+	for _, want := range []string{
+		// File ID, path, pages, lines and matches
+		`<td class="num">1</td><td><a href="#file-1">` + path + `</a></td><td class="num">1</td><td class="num">3</td><td class="num">2</td>`,
+		"2020-01-01T10:00:00Z ERROR a &lt; b &amp; c",
+		"2020-01-01T10:10:00Z ERROR &lt;script&gt;",
+		"<h2>Timeline</h2>",
+		"Matches per ",
+		`<div class="axis"><span>`,
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "<script>") || strings.Contains(report, "INFO ok") {
+		t.Errorf("report has unescaped or unmatched lines:\n%s", report)
+	}
+
+	// Lines without timestamps have no timeline
+	path, cleanup = writeTestFile(t, "ERROR one\nERROR two\n")
+	defer cleanup()
+	p = New(path, Options{Text: "plain", Filter: "ERROR", Lines: 10, Page: 1, NoColor: true})
+	if err := p.Report(out, 1); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = ioutil.ReadFile(out); err != nil {
+		t.Fatal(err)
+	}
+	if report := string(data); strings.Contains(report, "Timeline") || !strings.Contains(report, "1 more matching lines are not shown") {
+		t.Errorf("got report:\n%s", report)
+	}
+
+	// The errors are returned
+	if err := p.Report(filepath.Join(path, "missing", "report.html"), 0); err == nil {
+		t.Error("expected an error for a report in a missing folder")
+	}
+}
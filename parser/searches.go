@@ -0,0 +1,301 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Name of the project searches file, looked up in the working directory
+// It can be committed to share the searches with the team
+const projectSearches = ".logy-searches.yaml"
+
+// Names of saved searches, e.g. oom or nginx-5xx
+var searchNameReg = regexp.MustCompile(`^[\w.-]+$`)
+
+// savedSearch is a filter query saved under a name
+// Queries are always regex
+type savedSearch struct {
+	Query       string `yaml:"query"`
+	Description string `yaml:"description,omitempty"`
+	// File the search comes from. It is not saved
+	source string
+	// File of the search with the same name hidden by this one. It is not saved
+	shadows string
+}
+
+// shadowNotice tells that a search hides another one with the same name
+func (s savedSearch) shadowNotice(name string) string {
+	return fmt.Sprintf("Search %s from %s overrides the one from %s", name, s.source, s.shadows)
+}
+
+// searchPaths returns the searches files from the lowest to the highest priority
+// The user searches live next to the user config
+func searchPaths() []string {
+	paths := configPaths()
+	// The last config path is the project one
+	user := paths[:len(paths)-1]
+	for i, path := range user {
+		user[i] = filepath.Join(filepath.Dir(path), "searches.yaml")
+	}
+	return append(user, projectSearches)
+}
+
+// searchesPath returns the file where searches are saved
+func searchesPath(project bool) string {
+	paths := searchPaths()
+	if project || len(paths) == 1 {
+		return projectSearches
+	}
+	return paths[len(paths)-2]
+}
+
+// readSearches reads a searches file
+// Missing files are not an error, they have no searches
+func readSearches(path string) (map[string]savedSearch, error) {
+	searches := make(map[string]savedSearch)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return searches, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error! Cannot read searches file %s: %v", path, err)
+	}
+	if err := yaml.UnmarshalStrict(data, &searches); err != nil {
+		return nil, fmt.Errorf("Error! Invalid searches file %s: %v", path, err)
+	}
+	for name, s := range searches {
+		s.source = path
+		searches[name] = s
+	}
+	return searches, nil
+}
+
+// loadSearches merges the searches of all files
+// Project searches override user searches with the same name
+// and remember which file they override
+func loadSearches(paths []string) (map[string]savedSearch, error) {
+	all := make(map[string]savedSearch)
+	for _, path := range paths {
+		searches, err := readSearches(path)
+		if err != nil {
+			return nil, err
+		}
+		for name, s := range searches {
+			if prev, ok := all[name]; ok {
+				s.shadows = prev.source
+			}
+			all[name] = s
+		}
+	}
+	return all, nil
+}
+
+// findSearch returns the saved search with the given name
+func findSearch(paths []string, name string) (savedSearch, error) {
+	searches, err := loadSearches(paths)
+	if err != nil {
+		return savedSearch{}, err
+	}
+	s, ok := searches[name]
+	if !ok {
+		return savedSearch{}, fmt.Errorf("Error! There is no saved search called %q", name)
+	}
+	return s, nil
+}
+
+// writeSearches replaces the searches of a file
+func writeSearches(path string, searches map[string]savedSearch) error {
+	data, err := yaml.Marshal(searches)
+	if err != nil {
+		return fmt.Errorf("Error! Cannot save searches: %v", err)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("Error! Cannot save searches: %v", err)
+		}
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("Error! Cannot save searches: %v", err)
+	}
+	return nil
+}
+
+// saveSearch adds or replaces a search in a file
+func saveSearch(path, name string, s savedSearch) error {
+	if !searchNameReg.MatchString(name) {
+		return fmt.Errorf("Error! Search names can only contain letters, digits, dots, dashes and underscores")
+	}
+	if s.Query == "" {
+		return fmt.Errorf("Error! The query of a saved search cannot be empty")
+	}
+	if _, err := regexp.Compile(s.Query); err != nil {
+		return fmt.Errorf("Regex parse error: %s", err.Error())
+	}
+	searches, err := readSearches(path)
+	if err != nil {
+		return err
+	}
+	searches[name] = s
+	return writeSearches(path, searches)
+}
+
+// deleteSearch removes a search from a file
+func deleteSearch(path, name string) error {
+	searches, err := readSearches(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := searches[name]; !ok {
+		return fmt.Errorf("Error! There is no saved search called %q in %s", name, path)
+	}
+	delete(searches, name)
+	return writeSearches(path, searches)
+}
+
+// SaveSearch saves a regex query under a name
+// Searches go to the user searches file unless they are saved for the project
+func SaveSearch(name, query, description string, project bool) {
+	path := searchesPath(project)
+	if err := saveSearch(path, name, savedSearch{Query: query, Description: description}); err != nil {
+		exitWithError(err.Error())
+	}
+	fmt.Println(info(fmt.Sprintf("Search %s saved in %s", name, path)))
+}
+
+// DeleteSearch removes a saved search
+func DeleteSearch(name string, project bool) {
+	path := searchesPath(project)
+	if err := deleteSearch(path, name); err != nil {
+		exitWithError(err.Error())
+	}
+	fmt.Println(info(fmt.Sprintf("Search %s deleted from %s", name, path)))
+}
+
+// ListSearches prints all saved searches
+func ListSearches() {
+	searches, err := loadSearches(searchPaths())
+	if err != nil {
+		exitWithError(err.Error())
+	}
+	if len(searches) == 0 {
+		fmt.Printf("%s\n", info("Sorry. Nothing to show here!"))
+		return
+	}
+	renderSearches(os.Stdout, searches)
+}
+
+// renderSearches prints the saved searches as a table sorted by name
+func renderSearches(w io.Writer, searches map[string]savedSearch) {
+	table := newTable(w)
+	// Queries are easier to read on a single line
+	table.SetAutoWrapText(false)
+	setTableHeader(table, []string{"Name", "Query", "Description", "Saved In", "Overrides"})
+	for _, name := range searchNames(searches) {
+		s := searches[name]
+		table.Append([]string{name, s.Query, s.Description, s.source, s.shadows})
+	}
+	table.Render()
+}
+
+// searchNames returns the names of the searches in alphabetical order
+func searchNames(searches map[string]savedSearch) []string {
+	names := make([]string, 0, len(searches))
+	for name := range searches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// searchResult holds the stats of the files for a saved search
+type searchResult struct {
+	name string
+	savedSearch
+	stats []stats
+}
+
+// withQuery returns a copy of the parser filtering by a regex query
+func (p *Parser) withQuery(query string) (*Parser, error) {
+	q := *p
+	regex, err := compileFilter(query, true)
+	if err != nil {
+		return nil, fmt.Errorf("Regex parse error: %s", err.Error())
+	}
+	q.filter, q.regex, q.withRegex = query, regex, true
+	q.terms = filterTerms(query, regex)
+	return &q, nil
+}
+
+// RunSearches runs saved searches on the files and prints their stats
+// Names are separated by commas
+func (p *Parser) RunSearches(names string) {
+	results, err := p.runSearches(searchPaths(), names)
+	if err != nil {
+		exitWithError(err.Error())
+	}
+	for _, res := range results {
+		if res.shadows != "" {
+			fmt.Println(alert(res.shadowNotice(res.name)))
+		}
+	}
+	renderSearchStats(os.Stdout, results)
+}
+
+// runSearches runs the saved searches of the given files
+// Names are separated by commas
+func (p *Parser) runSearches(paths []string, names string) ([]searchResult, error) {
+	var results []searchResult
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		s, err := findSearch(paths, name)
+		if err != nil {
+			return nil, err
+		}
+		q, err := p.withQuery(s.Query)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, searchResult{name: name, savedSearch: s, stats: visibleStats(q.collectStats())})
+	}
+	if len(results) == 0 {
+		return nil, errors.New("Error! At least one saved search is required")
+	}
+	return results, nil
+}
+
+// renderSearchStats prints the stats table with the matches of every search
+// Files without matches are left out
+func renderSearchStats(w io.Writer, results []searchResult) {
+	table := newTable(w)
+	table.SetAutoWrapText(false)
+	setTableHeader(table, []string{"Search", "File Path", "Number of Pages", "Number of Matches"})
+	var matched int
+	for _, res := range results {
+		var total int
+		for _, stat := range res.stats {
+			total += stat.matches
+			table.Append([]string{res.name, stat.path, strconv.Itoa(len(stat.offsets)), strconv.Itoa(stat.matches)})
+		}
+		if len(res.stats) == 0 {
+			table.Append([]string{res.name, "-", "0", "0"})
+		}
+		if total > 0 {
+			matched++
+		}
+	}
+	fmt.Fprintln(w, info(fmt.Sprintf("%d of %d searches have matches", matched, len(results))))
+	table.Render()
+}
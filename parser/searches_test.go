@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSavedSearches tests if searches are saved, merged and deleted
+func TestSavedSearches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	user := filepath.Join(dir, "config", "logy", "searches.yaml")
+	project := filepath.Join(dir, ".logy-searches.yaml")
+	paths := []string{user, project}
+
+	if err := saveSearch(user, "oom", savedSearch{Query: "(OOM|Killed process)", Description: "Out of memory"}); err != nil {
+		t.Fatalf("saveSearch returned error: %v", err)
+	}
+	if err := saveSearch(user, "panic", savedSearch{Query: "panic:"}); err != nil {
+		t.Fatalf("saveSearch returned error: %v", err)
+	}
+	// The project search wins over the user one with the same name
+	if err := saveSearch(project, "panic", savedSearch{Query: "panic: .*goroutine"}); err != nil {
+		t.Fatalf("saveSearch returned error: %v", err)
+	}
+	for _, tt := range []struct {
+		name, query string
+		s           savedSearch
+	}{
+		{"bad name", "", savedSearch{Query: "x"}},
+		{"empty", "", savedSearch{}},
+		{"invalid", "", savedSearch{Query: "("}},
+	} {
+		if err := saveSearch(user, tt.name, tt.s); err == nil {
+			t.Errorf("expected an error when saving %q", tt.name)
+		}
+	}
+
+	searches, err := loadSearches(paths)
+	if err != nil {
+		t.Fatalf("loadSearches returned error: %v", err)
+	}
+	if got := strings.Join(searchNames(searches), ","); got != "oom,panic" {
+		t.Errorf("got searches %s, want oom,panic", got)
+	}
+	if s := searches["oom"]; s.Query != "(OOM|Killed process)" || s.Description != "Out of memory" || s.source != user || s.shadows != "" {
+		t.Errorf("got oom search %+v", s)
+	}
+	// The project search tells which search it hides
+	if s := searches["panic"]; s.Query != "panic: .*goroutine" || s.source != project || s.shadows != user {
+		t.Errorf("got panic search %+v", s)
+	}
+	var buf bytes.Buffer
+	renderSearches(&buf, searches)
+	if !strings.Contains(buf.String(), "OVERRIDES") || !strings.Contains(buf.String(), user) {
+		t.Errorf("the overridden search is not listed:\n%s", buf.String())
+	}
+
+	// Deleting the project search brings back the user one
+	if err := deleteSearch(project, "panic"); err != nil {
+		t.Fatalf("deleteSearch returned error: %v", err)
+	}
+	if s, err := findSearch(paths, "panic"); err != nil || s.Query != "panic:" {
+		t.Errorf("findSearch(panic) = %+v, %v", s, err)
+	}
+	if err := deleteSearch(project, "panic"); err == nil {
+		t.Error("expected an error when deleting a missing search")
+	}
+	if _, err := findSearch(paths, "missing"); err == nil {
+		t.Error("expected an error for a missing search")
+	}
+}
+
+// TestRenderSearchStats tests the stats table of the saved searches
+func TestRenderSearchStats(t *testing.T) {
+	var buf bytes.Buffer
+	renderSearchStats(&buf, []searchResult{
+		{name: "oom", stats: []stats{{path: "a.log", offsets: []int64{0, 10}, matches: 3}}},
+		{name: "panic"},
+	})
+	out := buf.String()
+	for _, want := range []string{"1 of 2 searches have matches", "| oom    | a.log     |               2 |                 3 |", "| panic  | -         |               0 |                 0 |"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+// TestRunSearches tests if every saved search gets the stats of its matches
+// Errors are returned for missing searches and invalid queries
+func TestRunSearches(t *testing.T) {
+	path, cleanup := writeTestFile(t, "INFO start\nERROR failed\nFATAL stopped\n")
+	defer cleanup()
+	dir := filepath.Dir(path)
+	user := filepath.Join(dir, "searches.yaml")
+	project := filepath.Join(dir, ".logy-searches.yaml")
+	paths := []string{user, project}
+	if err := saveSearch(user, "errors", savedSearch{Query: "ERROR"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveSearch(project, "errors", savedSearch{Query: "ERROR|FATAL"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveSearch(user, "oom", savedSearch{Query: "OOM"}); err != nil {
+		t.Fatal(err)
+	}
+	// Invalid queries can only come from a file edited by hand
+	if err := writeSearches(filepath.Join(dir, "broken.yaml"), map[string]savedSearch{"broken": {Query: "(oops"}}); err != nil {
+		t.Fatal(err)
+	}
+	p := New(path, Options{Text: "plain", Lines: 10, Page: 1, NoColor: true})
+
+	results, err := p.runSearches(paths, "errors, oom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || len(results[0].stats) != 1 || results[0].stats[0].matches != 2 || len(results[1].stats) != 0 {
+		t.Errorf("got results %+v", results)
+	}
+	if got, want := results[0].shadowNotice("errors"), "Search errors from "+project+" overrides the one from "+user; got != want {
+		t.Errorf("got notice %q, want %q", got, want)
+	}
+	for _, tt := range []struct {
+		paths []string
+		names string
+		err   string
+	}{
+		{paths, "errors,missing", `Error! There is no saved search called "missing"`},
+		{paths, " , ", "Error! At least one saved search is required"},
+		{[]string{filepath.Join(dir, "broken.yaml")}, "broken", "Regex parse error"},
+	} {
+		if _, err := p.runSearches(tt.paths, tt.names); err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("runSearches(%q) returned error %v, want %s", tt.names, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of search results returned when no limit is given
+const searchLimit = 100
+
+// Number of searches that can read the files at the same time
+const maxSearches = 4
+
+// pageData is a page returned by the HTTP API
+type pageData struct {
+	ID    int    `json:"id"`
+	File  string `json:"file"`
+	Page  int    `json:"page"`
+	Pages int    `json:"pages"`
+	// Number of the first line of the page
+	Line int    `json:"line"`
+	Text string `json:"text"`
+	// Text with the highlights converted to HTML
+	HTML string `json:"html"`
+}
+
+// searchHit is a matching line returned by the search endpoint
+// The ID and page point to the page showing the line in /files
+// They are 0 when the line is hidden by the filter of the server
+type searchHit struct {
+	ID int `json:"id"`
+	record
+}
+
+// searchData is the response of the search endpoint
+// The pages of the files are the pages matching the query
+type searchData struct {
+	Query   string      `json:"query"`
+	Files   []summary   `json:"files"`
+	Hits    []searchHit `json:"hits"`
+	Matches int         `json:"matches"`
+	// Tells if there were more hits than the limit
+	Truncated bool `json:"truncated"`
+}
+
+// server exposes the files over HTTP
+// The stats are computed once and never modified so all requests share them without locking
+// Every request opens its own file handles
+type server struct {
+	p *Parser
+	// Stats for all files
+	all []stats
+	// Stats for the files shown by /files
+	fs []stats
+	// Limits the number of searches running at the same time
+	searches chan struct{}
+	mux      *http.ServeMux
+}
+
+// Serve starts an HTTP server with a JSON API and a web viewer for the files
+func (p *Parser) Serve(addr string) {
+	all := p.collectStats()
+	// Highlights are converted to CSS classes so they must be colored
+	// unless the user explicitly disabled colors
+	// This is done before serving since the setting is shared by all requests
+	// Only the basic colors have CSS classes
+	if !p.noColor {
+		forceBasicColors()
+	}
+	s := newServer(p, all)
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      s,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: time.Minute,
+	}
+	fmt.Println(info(fmt.Sprintf("Serving %d files on http://%s", len(s.fs), addr)))
+	if err := srv.ListenAndServe(); err != nil {
+		exitWithError(fmt.Sprintf("Error! Cannot start server: %v", err))
+	}
+}
+
+// newServer returns a server for the given file stats
+func newServer(p *Parser, all []stats) *server {
+	s := &server{
+		p:        p,
+		all:      all,
+		fs:       visibleStats(all),
+		searches: make(chan struct{}, maxSearches),
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/", s.handleUI)
+	s.mux.HandleFunc("/files", s.handleFiles)
+	s.mux.HandleFunc("/files/", s.handlePage)
+	s.mux.HandleFunc("/search", s.handleSearch)
+	return s
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		httpError(w, http.StatusMethodNotAllowed, "Error! Only GET requests are accepted")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleUI serves the web viewer
+func (s *server) handleUI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		httpError(w, http.StatusNotFound, "Error! Page not found")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, serverUI)
+}
+
+// handleFiles lists the stats of all files
+func (s *server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	files := make([]summary, 0, len(s.fs))
+	for i, stat := range s.fs {
+		files = append(files, summary{ID: i + 1, File: stat.path, Pages: len(stat.offsets), Lines: stat.lines, Matches: stat.matches})
+	}
+	writeJSON(w, files)
+}
+
+// handlePage serves a page of a file: /files/{id}/pages/{n}
+func (s *server) handlePage(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 4 || parts[2] != "pages" {
+		httpError(w, http.StatusNotFound, "Error! Page not found")
+		return
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil || id < 1 || id > len(s.fs) {
+		httpError(w, http.StatusNotFound, fmt.Sprintf("Error! File ID must be between 1 and %d", len(s.fs)))
+		return
+	}
+	stat := s.fs[id-1]
+	page, err := strconv.Atoi(parts[3])
+	if err != nil || page < 1 || page > len(stat.offsets) {
+		httpError(w, http.StatusNotFound, fmt.Sprintf("Error! Page number must be between 1 and %d", len(stat.offsets)))
+		return
+	}
+	start := stat.firstLine(page)
+	text, err := s.p.getFilePage(stat.path, stat.offsets[page-1], start)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, fmt.Sprintf("Error! Cannot read page: %v", err))
+		return
+	}
+	writeJSON(w, pageData{
+		ID:    id,
+		File:  stat.path,
+		Page:  page,
+		Pages: len(stat.offsets),
+		Line:  start,
+		Text:  stripANSI(text),
+		HTML:  ansiToHTML(text),
+	})
+}
+
+// handleSearch finds the lines matching the query in all files: /search?q=
+// The exclusion of the server still applies
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		httpError(w, http.StatusBadRequest, "Error! Query parameter q is required")
+		return
+	}
+	limit := searchLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			httpError(w, http.StatusBadRequest, "Error! Query parameter limit must be strictly positive")
+			return
+		}
+		limit = n
+	}
+	np, err := s.p.withFilter(query, s.p.exclude)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Wait for a free slot so concurrent searches do not overload the disk
+	select {
+	case s.searches <- struct{}{}:
+		defer func() { <-s.searches }()
+	case <-r.Context().Done():
+		return
+	}
+	all, err := np.rematch(s.all)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, fmt.Sprintf("Error! Cannot search files: %v", err))
+		return
+	}
+	res := searchData{Query: query, Files: []summary{}, Hits: []searchHit{}}
+	for _, stat := range visibleStats(all) {
+		// Files keep the IDs used by /files
+		id, view := s.viewOf(stat.path)
+		res.Files = append(res.Files, summary{ID: id, File: stat.path, Pages: len(stat.offsets), Lines: stat.lines, Matches: stat.matches})
+		res.Matches += stat.matches
+		if res.Truncated {
+			continue
+		}
+		// The reading stops at the first hit over the limit
+		err := np.eachRecord(stat, func(rec record) bool {
+			if len(res.Hits) >= limit {
+				res.Truncated = true
+				return false
+			}
+			// The page of the search is replaced by the page shown by /files
+			hit := searchHit{record: rec}
+			hit.Page = 0
+			if p, ok := view.findLine(rec.Line); ok {
+				hit.ID, hit.Page = id, p
+			}
+			res.Hits = append(res.Hits, hit)
+			return true
+		})
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, fmt.Sprintf("Error! Cannot search files: %v", err))
+			return
+		}
+	}
+	writeJSON(w, res)
+}
+
+// viewOf returns the ID and the stats of a file as shown by /files
+func (s *server) viewOf(path string) (int, stats) {
+	for i, stat := range s.fs {
+		if stat.path == path {
+			return i + 1, stat
+		}
+	}
+	return 0, stats{}
+}
+
+// writeJSON sends a value encoded as JSON
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		httpError(w, http.StatusInternalServerError, fmt.Sprintf("Error! Cannot encode response: %v", err))
+	}
+}
+
+// httpError sends an error message encoded as JSON
+func httpError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// The web viewer
+// It only uses the JSON API so it is a single page without dependencies
+const serverUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Logy</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 0; display: flex; height: 100vh; color: #222; }
+nav { width: 280px; overflow-y: auto; border-right: 1px solid #ddd; padding: 1em; box-sizing: border-box; }
+nav a { display: block; padding: .3em; color: #222; text-decoration: none; word-break: break-all; }
+nav a.active { background: #e8f0fe; }
+nav small { color: #666; }
+main { flex: 1; display: flex; flex-direction: column; min-width: 0; }
+header { padding: .6em 1em; border-bottom: 1px solid #ddd; display: flex; gap: .5em; align-items: center; }
+header input[type=search] { flex: 1; padding: .3em; }
+#status { color: #666; }
+pre { flex: 1; margin: 0; background: #1e1e1e; color: #ddd; padding: 1em; overflow: auto; line-height: 1.4; }
+#results { max-height: 40vh; overflow-y: auto; border-bottom: 1px solid #ddd; }
+#results a { display: block; padding: .2em 1em; font-family: monospace; color: #222; text-decoration: none; white-space: nowrap; overflow: hidden; text-overflow: ellipsis; }
+#results a:hover { background: #f4f4f4; }
+` + ansiStyles + `</style>
+</head>
+<body>
+<nav id="files"></nav>
+<main>
+<header>
+<button id="prev">&larr;</button>
+<span id="status"></span>
+<button id="next">&rarr;</button>
+<form id="search" style="flex: 1; display: flex; gap: .5em"><input type="search" id="q" placeholder="Search all files"><button>Search</button></form>
+</header>
+<div id="results"></div>
+<pre id="page"></pre>
+</main>
+<script>
+var files = [], current = {id: 0, page: 0, pages: 0};
+function $(id) { return document.getElementById(id); }
+function get(url) {
+  return fetch(url).then(function(r) {
+    return r.json().then(function(data) {
+      if (!r.ok) { throw new Error(data.error); }
+      return data;
+    });
+  });
+}
+function showError(err) { $("status").textContent = err.message; }
+function showPage(id, page) {
+  get("/files/" + id + "/pages/" + page).then(function(data) {
+    current = data;
+    $("page").innerHTML = data.html;
+    $("page").scrollTop = 0;
+    $("status").textContent = data.file + " - page " + data.page + " of " + data.pages;
+    var links = $("files").querySelectorAll("a");
+    for (var i = 0; i < links.length; i++) {
+      links[i].className = i + 1 === id ? "active" : "";
+    }
+  }).catch(showError);
+}
+$("prev").onclick = function() { if (current.page > 1) { showPage(current.id, current.page - 1); } };
+$("next").onclick = function() { if (current.page < current.pages) { showPage(current.id, current.page + 1); } };
+$("search").onsubmit = function(e) {
+  e.preventDefault();
+  var q = $("q").value;
+  $("results").innerHTML = "";
+  if (!q) { return; }
+  get("/search?q=" + encodeURIComponent(q)).then(function(data) {
+    if (data.hits.length === 0) {
+      $("results").textContent = "Sorry. Nothing to show here!";
+      return;
+    }
+    data.hits.forEach(function(hit) {
+      var a = document.createElement("a");
+      a.href = "#";
+      a.textContent = hit.file + ":" + hit.line + ": " + hit.text;
+      a.onclick = function(e) {
+        e.preventDefault();
+        if (hit.id > 0) { showPage(hit.id, hit.page); }
+      };
+      $("results").appendChild(a);
+    });
+  }).catch(showError);
+};
+get("/files").then(function(data) {
+  files = data;
+  if (files.length === 0) {
+    $("page").textContent = "Sorry. Nothing to show here!";
+    return;
+  }
+  files.forEach(function(f) {
+    var a = document.createElement("a");
+    a.href = "#";
+    a.innerHTML = "<span></span><br><small></small>";
+    a.firstChild.textContent = f.file;
+    a.lastChild.textContent = f.pages + " pages, " + f.lines + " lines, " + f.matches + " matches";
+    a.onclick = function(e) { e.preventDefault(); showPage(f.id, 1); };
+    $("files").appendChild(a);
+  });
+  showPage(1, 1);
+}).catch(showError);
+</script>
+</body>
+</html>
+`
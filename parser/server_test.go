@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestServer starts a server for a temporary file with 10 lines
+// and 3 lines per page
+func newTestServer(t *testing.T, opts Options) (*httptest.Server, func()) {
+	t.Helper()
+	path, cleanup := writeTestLog(t, 10, func(i int) string {
+		if i%4 == 0 {
+			return "ERROR"
+		}
+		return "INFO"
+	})
+	opts.Text, opts.Lines, opts.Page, opts.NoColor = "plain", 3, 1, true
+	p := New(path, opts)
+	ts := httptest.NewServer(newServer(p, p.collectStats()))
+	return ts, func() {
+		ts.Close()
+		cleanup()
+	}
+}
+
+// getJSON decodes the response of a GET request
+// It reports false when the request failed
+// Errors are not fatal so it can be used by concurrent requests
+func getJSON(t *testing.T, url string, status int, v interface{}) bool {
+	t.Helper()
+	res, err := http.Get(url)
+	if err != nil {
+		t.Error(err)
+		return false
+	}
+	defer res.Body.Close()
+	if res.StatusCode != status {
+		t.Errorf("GET %s: status %d, want %d", url, res.StatusCode, status)
+		return false
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		t.Errorf("GET %s: %v", url, err)
+		return false
+	}
+	return true
+}
+
+// TestServerFiles tests if the file stats are listed
+func TestServerFiles(t *testing.T) {
+	ts, cleanup := newTestServer(t, Options{Filter: "ERROR"})
+	defer cleanup()
+
+	var files []summary
+	if !getJSON(t, ts.URL+"/files", http.StatusOK, &files) || len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	// Lines 4 and 8 are on pages 2 and 3
+	want := summary{ID: 1, File: files[0].File, Pages: 2, Lines: 10, Matches: 2}
+	if files[0] != want {
+		t.Errorf("got %+v, want %+v", files[0], want)
+	}
+}
+
+// TestServerPage tests if pages are served and invalid pages are rejected
+func TestServerPage(t *testing.T) {
+	ts, cleanup := newTestServer(t, Options{})
+	defer cleanup()
+
+	var page pageData
+	getJSON(t, ts.URL+"/files/1/pages/2", http.StatusOK, &page)
+	if page.Pages != 4 || page.Line != 4 || page.Text != "ERROR line 4\nINFO line 5\nINFO line 6\n" {
+		t.Errorf("unexpected page %+v", page)
+	}
+
+	var e map[string]string
+	for _, url := range []string{"/files/2/pages/1", "/files/1/pages/5", "/files/1/pages/x", "/files/1", "/nothing"} {
+		getJSON(t, ts.URL+url, http.StatusNotFound, &e)
+	}
+}
+
+// TestServerSearch tests if concurrent searches return the matching lines
+func TestServerSearch(t *testing.T) {
+	ts, cleanup := newTestServer(t, Options{})
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var res searchData
+			if !getJSON(t, ts.URL+"/search?q=ERROR", http.StatusOK, &res) {
+				return
+			}
+			if res.Matches != 2 || len(res.Hits) != 2 || res.Truncated {
+				t.Errorf("unexpected search result %+v", res)
+				return
+			}
+			if hit := res.Hits[1]; hit.Line != 8 || hit.ID != 1 || hit.Page != 3 {
+				t.Errorf("unexpected hit %+v", hit)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var res searchData
+	getJSON(t, ts.URL+"/search?q=line&limit=3", http.StatusOK, &res)
+	if len(res.Hits) != 3 || !res.Truncated || res.Matches != 10 {
+		t.Errorf("unexpected limited search result %+v", res)
+	}
+	// The limit stops the search in the middle of a page
+	res = searchData{}
+	getJSON(t, ts.URL+"/search?q=line&limit=1", http.StatusOK, &res)
+	if len(res.Hits) != 1 || !res.Truncated || res.Hits[0].Line != 1 {
+		t.Errorf("unexpected limited search result %+v", res)
+	}
+	var e map[string]string
+	getJSON(t, ts.URL+"/search", http.StatusBadRequest, &e)
+}
+
+// TestServerReadError tests if files that cannot be read give an error response
+// The server must keep running
+func TestServerReadError(t *testing.T) {
+	ts, cleanup := newTestServer(t, Options{})
+	defer cleanup()
+
+	var files []summary
+	if !getJSON(t, ts.URL+"/files", http.StatusOK, &files) {
+		return
+	}
+	if err := os.Remove(files[0].File); err != nil {
+		t.Fatal(err)
+	}
+	var e map[string]string
+	getJSON(t, ts.URL+"/files/1/pages/1", http.StatusInternalServerError, &e)
+	if !strings.Contains(e["error"], "Cannot read page") {
+		t.Errorf("unexpected error %v", e)
+	}
+	getJSON(t, ts.URL+"/search?q=ERROR", http.StatusInternalServerError, &e)
+	if !strings.Contains(e["error"], "Cannot search files") {
+		t.Errorf("unexpected error %v", e)
+	}
+}
@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Flags like (?i) at the start of a regex apply to every alternative
+var regexFlagsReg = regexp.MustCompile(`^\(\?[imsU]+\)`)
+
+// filterTerm is a part of a regex filter colored on its own
+type filterTerm struct {
+	// Text shown in the legend
+	label string
+	// Capture group of the term (0 when the term is an alternative)
+	group int
+	// Regex matching the whole text of an alternative
+	reg *regexp.Regexp
+}
+
+// filterTerms splits a regex filter into the terms colored on their own
+// Capture groups are the terms when there are any, otherwise the alternatives of the top level
+// Filters with less than 2 terms have no terms and their matches get the same color
+func filterTerms(filter string, regex *regexp.Regexp) []filterTerm {
+	if regex == nil {
+		return nil
+	}
+	alternatives, groups := scanRegex(filter)
+	var terms []filterTerm
+	if n := regex.NumSubexp(); n > 0 {
+		names := regex.SubexpNames()
+		for i := 1; i <= n; i++ {
+			label := names[i]
+			if label == "" && len(groups) == n {
+				label = groups[i-1]
+			}
+			if label == "" {
+				label = fmt.Sprintf("group %d", i)
+			}
+			terms = append(terms, filterTerm{label: label, group: i})
+		}
+	} else {
+		flags := regexFlagsReg.FindString(filter)
+		for _, alt := range alternatives {
+			reg, err := regexp.Compile(flags + `^(?:` + strings.TrimPrefix(alt, flags) + `)$`)
+			if err != nil {
+				return nil
+			}
+			terms = append(terms, filterTerm{label: alt, reg: reg})
+		}
+	}
+	if len(terms) < 2 {
+		return nil
+	}
+	return terms
+}
+
+// scanRegex returns the alternatives of the top level of a regex and the text of its capture groups
+func scanRegex(filter string) ([]string, []string) {
+	type open struct {
+		start   int
+		capture bool
+		index   int
+	}
+	var (
+		stack        []open
+		alternatives []string
+		groups       []string
+		inClass      bool
+		last         int
+	)
+	for i := 0; i < len(filter); i++ {
+		c := filter[i]
+		switch {
+		case c == '\\':
+			i++
+		case inClass:
+			if strings.HasPrefix(filter[i:], "[:") {
+				// Named classes like [:alpha:] live inside brackets
+				if end := strings.Index(filter[i:], ":]"); end > 0 {
+					i += end + 1
+				}
+			} else if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+			// A ] right after [ or [^ is a regular character
+			if strings.HasPrefix(filter[i+1:], "^") {
+				i++
+			}
+			if strings.HasPrefix(filter[i+1:], "]") {
+				i++
+			}
+		case c == '(':
+			o := open{start: i + 1, capture: true}
+			if strings.HasPrefix(filter[i+1:], "?") {
+				o.capture = strings.HasPrefix(filter[i+1:], "?P<")
+				if o.capture {
+					o.start = i + strings.IndexByte(filter[i:], '>') + 1
+				}
+			}
+			if o.capture {
+				o.index = len(groups)
+				groups = append(groups, "")
+			}
+			stack = append(stack, o)
+		case c == ')':
+			if len(stack) == 0 {
+				continue
+			}
+			o := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if o.capture {
+				groups[o.index] = filter[o.start:i]
+			}
+		case c == '|' && len(stack) == 0:
+			alternatives = append(alternatives, filter[last:i])
+			last = i + 1
+		}
+	}
+	return append(alternatives, filter[last:]), groups
+}
+
+// termSpans finds the matches of a regex filter and colors every term with its own color
+func (p *Parser) termSpans(text string) []colorSpan {
+	match := currentTheme.get("match")
+	var spans []colorSpan
+	for _, m := range p.regex.FindAllStringSubmatchIndex(text, -1) {
+		if len(p.terms) == 0 {
+			spans = append(spans, colorSpan{m[0], m[1], match})
+			continue
+		}
+		if p.terms[0].group > 0 {
+			// The text around the groups keeps the color of the matches
+			spans = append(spans, colorSpan{m[0], m[1], match})
+			for i, t := range p.terms {
+				if start, end := m[2*t.group], m[2*t.group+1]; start >= 0 && start < end {
+					spans = append(spans, colorSpan{start, end, currentTheme.list("terms", i)})
+				}
+			}
+			continue
+		}
+		s := match
+		for i, t := range p.terms {
+			if t.reg.MatchString(text[m[0]:m[1]]) {
+				s = currentTheme.list("terms", i)
+				break
+			}
+		}
+		spans = append(spans, colorSpan{m[0], m[1], s})
+	}
+	return spans
+}
+
+// legend returns the line telling the color of every term of the filter
+// Without colors there is nothing to tell
+func (p *Parser) legend() string {
+	if len(p.terms) == 0 || color.NoColor {
+		return ""
+	}
+	parts := make([]string, len(p.terms))
+	for i, t := range p.terms {
+		parts[i] = currentTheme.list("terms", i).sprint(t.label)
+	}
+	return fmt.Sprintf("%s %s\n", info("Terms:"), strings.Join(parts, "  "))
+}
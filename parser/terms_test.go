@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// TestScanRegex tests finding the alternatives and the capture groups of a regex
+func TestScanRegex(t *testing.T) {
+	tests := []struct {
+		filter       string
+		alternatives []string
+		groups       []string
+	}{
+		{`user=\d+`, []string{`user=\d+`}, nil},
+		{`user=\d+|order=\d+`, []string{`user=\d+`, `order=\d+`}, nil},
+		{`(?:a|b)|c\|d`, []string{`(?:a|b)`, `c\|d`}, nil},
+		{`[|(]x|y`, []string{`[|(]x`, `y`}, nil},
+		{`[]|]x|[[:alpha:]|]`, []string{`[]|]x`, `[[:alpha:]|]`}, nil},
+		{`user=(?P<user>\d+) (order=(\d+))`, []string{`user=(?P<user>\d+) (order=(\d+))`}, []string{`\d+`, `order=(\d+)`, `\d+`}},
+	}
+	for _, tt := range tests {
+		alternatives, groups := scanRegex(tt.filter)
+		if !reflect.DeepEqual(alternatives, tt.alternatives) {
+			t.Errorf("scanRegex(%q) alternatives = %q, want %q", tt.filter, alternatives, tt.alternatives)
+		}
+		if !reflect.DeepEqual(groups, tt.groups) {
+			t.Errorf("scanRegex(%q) groups = %q, want %q", tt.filter, groups, tt.groups)
+		}
+	}
+}
+
+// TestFilterTerms tests the terms colored on their own
+func TestFilterTerms(t *testing.T) {
+	tests := []struct {
+		filter string
+		labels []string
+	}{
+		{`user=\d+`, nil},
+		{`user=(\d+)`, nil},
+		{`user=\d+|order=\d+`, []string{`user=\d+`, `order=\d+`}},
+		{`user=(?P<user>\d+) order=(\d+)`, []string{"user", `\d+`}},
+		{`(a)|(b)`, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		var labels []string
+		for _, term := range filterTerms(tt.filter, regexp.MustCompile(tt.filter)) {
+			labels = append(labels, term.label)
+		}
+		if !reflect.DeepEqual(labels, tt.labels) {
+			t.Errorf("filterTerms(%q) = %q, want %q", tt.filter, labels, tt.labels)
+		}
+	}
+	if terms := filterTerms("user", nil); terms != nil {
+		t.Errorf("filterTerms() without regex = %v, want nil", terms)
+	}
+}
+
+// TestTermSpans tests that every term of the filter gets its own color
+func TestTermSpans(t *testing.T) {
+	tests := []struct {
+		filter string
+		text   string
+		// Span bounds and the index of the term (-1 is the color of the matches)
+		want [][3]int
+	}{
+		{`user=\d+`, "user=42 order=99", [][3]int{{0, 7, -1}}},
+		{`user=\d+|order=\d+`, "user=42 order=99 user=7", [][3]int{{0, 7, 0}, {8, 16, 1}, {17, 23, 0}}},
+		{`(?i)USER=\d+|order=\d+`, "user=42", [][3]int{{0, 7, 0}}},
+		{`user=(\d+) order=(\d+)`, "user=42 order=99", [][3]int{{0, 16, -1}, {5, 7, 0}, {14, 16, 1}}},
+	}
+	for _, tt := range tests {
+		regex := regexp.MustCompile(tt.filter)
+		p := &Parser{filter: tt.filter, regex: regex, terms: filterTerms(tt.filter, regex)}
+		spans := p.termSpans(tt.text)
+		if len(spans) != len(tt.want) {
+			t.Errorf("termSpans(%q) found %d spans, want %d", tt.filter, len(spans), len(tt.want))
+			continue
+		}
+		for i, s := range spans {
+			want := currentTheme.get("match")
+			if tt.want[i][2] >= 0 {
+				want = currentTheme.list("terms", tt.want[i][2])
+			}
+			if s.start != tt.want[i][0] || s.end != tt.want[i][1] || !reflect.DeepEqual(s.style, want) {
+				t.Errorf("termSpans(%q) span %d = %d-%d %v, want %v", tt.filter, i, s.start, s.end, s.style, tt.want[i])
+			}
+		}
+	}
+}
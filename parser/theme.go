@@ -0,0 +1,592 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+)
+
+// Number of colors the terminal can show
+const (
+	colors16 = iota
+	colors256
+	colorsTrue
+)
+
+// Parts of the output that can be colored by a theme
+// Lists (terms and tags) hold many styles separated by commas
+var themeKeys = []string{
+	"match",
+	"error",
+	"alert",
+	"info",
+	"terms",
+	"level.error",
+	"level.warn",
+	"level.info",
+	"level.debug",
+	"json.key",
+	"json.string",
+	"json.number",
+	"json.bool",
+	"json.null",
+	"xml.tag",
+	"xml.attr",
+	"comment",
+	"table.header",
+	"diff.added",
+	"diff.removed",
+	"diff.hunk",
+	"tags",
+}
+
+// Built-in themes
+// A style is a list of words: attributes (bold, dim, italic, underline, reverse),
+// a text color and a background color starting with "on-".
+// Colors are names (red, hi-red, ...), numbers from the 256 color palette or #rrggbb
+var themes = map[string]map[string]string{
+	"dark": {
+		"match":        "hi-green bold",
+		"error":        "hi-white on-red bold",
+		"alert":        "hi-yellow bold",
+		"info":         "hi-magenta bold",
+		"terms":        "hi-green bold, hi-cyan bold, hi-yellow bold, hi-magenta bold, hi-blue bold, hi-red bold",
+		"level.error":  "hi-red",
+		"level.warn":   "hi-yellow",
+		"level.info":   "hi-cyan",
+		"level.debug":  "hi-black",
+		"json.key":     "hi-blue",
+		"json.string":  "green",
+		"json.number":  "cyan",
+		"json.bool":    "yellow",
+		"json.null":    "hi-black",
+		"xml.tag":      "hi-blue",
+		"xml.attr":     "cyan",
+		"comment":      "hi-black italic",
+		"table.header": "bold",
+		"diff.added":   "hi-green",
+		"diff.removed": "hi-red",
+		"diff.hunk":    "hi-cyan",
+		"tags":         "hi-cyan, hi-blue, hi-green, hi-yellow, hi-magenta, cyan, blue, green, yellow, magenta",
+	},
+	"light": {
+		"match":        "green bold",
+		"error":        "white on-red bold",
+		"alert":        "#9a6700 bold",
+		"info":         "magenta bold",
+		"terms":        "green bold, blue bold, #9a6700 bold, magenta bold, cyan bold, red bold",
+		"level.error":  "red",
+		"level.warn":   "#9a6700",
+		"level.info":   "blue",
+		"level.debug":  "#6e7781",
+		"json.key":     "blue",
+		"json.string":  "green",
+		"json.number":  "#0550ae",
+		"json.bool":    "#9a6700",
+		"json.null":    "#6e7781",
+		"xml.tag":      "blue",
+		"xml.attr":     "#0550ae",
+		"comment":      "#6e7781 italic",
+		"table.header": "blue bold",
+		"diff.added":   "green",
+		"diff.removed": "red",
+		"diff.hunk":    "blue",
+		"tags":         "blue, green, magenta, cyan, red, #9a6700, #0550ae, #116329, #8250df, #bc4c00",
+	},
+	"high-contrast": {
+		"match":        "black on-hi-green bold",
+		"error":        "hi-white on-red bold",
+		"alert":        "black on-hi-yellow bold",
+		"info":         "hi-cyan bold underline",
+		"terms":        "black on-hi-green, black on-hi-cyan, black on-hi-yellow, black on-hi-magenta, hi-white on-blue, hi-white on-red",
+		"level.error":  "hi-white on-red bold",
+		"level.warn":   "black on-hi-yellow bold",
+		"level.info":   "hi-cyan bold",
+		"level.debug":  "hi-white",
+		"json.key":     "hi-cyan bold",
+		"json.string":  "hi-green",
+		"json.number":  "hi-yellow",
+		"json.bool":    "hi-magenta",
+		"json.null":    "hi-white",
+		"xml.tag":      "hi-cyan bold",
+		"xml.attr":     "hi-yellow",
+		"comment":      "hi-white italic",
+		"table.header": "hi-white bold underline",
+		"diff.added":   "black on-hi-green",
+		"diff.removed": "hi-white on-red",
+		"diff.hunk":    "hi-cyan bold",
+		"tags":         "hi-cyan bold, hi-yellow bold, hi-green bold, hi-magenta bold, hi-white bold",
+	},
+}
+
+// Names of the basic colors, in the order of their ANSI codes
+var colorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// SGR codes of the text attributes
+var colorAttrs = map[string]int{
+	"bold":      1,
+	"dim":       2,
+	"italic":    3,
+	"underline": 4,
+	"reverse":   7,
+}
+
+// RGB values of the 16 basic colors, as shown by xterm
+var basicRGB = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0}, {0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0}, {92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// Levels of the 6x6x6 color cube of the 256 color palette
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// Number of colors of the terminal
+// Truecolor and 256 colors are downgraded when the terminal cannot show them
+var colorLevel = detectColorLevel()
+
+// The theme used for every colored output
+var currentTheme = mustTheme("dark", nil)
+
+// Colors set by the config files on top of the theme
+var colorOverrides map[string]string
+
+// init honors the NO_COLOR and CLICOLOR_FORCE environment variables
+// NO_COLOR wins since it is an explicit request to drop colors
+func init() {
+	if forceColors() {
+		color.NoColor = false
+	}
+	if noColorEnv() {
+		color.NoColor = true
+	}
+}
+
+// noColorEnv tells if colors are disabled by NO_COLOR
+func noColorEnv() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
+
+// forceColors tells if colors are forced by CLICOLOR_FORCE even without a terminal
+func forceColors() bool {
+	v := os.Getenv("CLICOLOR_FORCE")
+	return v != "" && v != "0"
+}
+
+// detectColorLevel finds how many colors the terminal can show
+func detectColorLevel() int {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return colorsTrue
+	}
+	if strings.Contains(os.Getenv("TERM"), "256") {
+		return colors256
+	}
+	return colors16
+}
+
+// termColor is a text or background color
+type termColor struct {
+	// One of colors16, colors256 or colorsTrue
+	kind    int
+	index   int
+	r, g, b int
+}
+
+// codes returns the SGR codes of the color for the given number of colors
+func (c termColor) codes(level int, bg bool) string {
+	kind, index := c.kind, c.index
+	if kind == colorsTrue && level < colorsTrue {
+		kind, index = colors256, rgbTo256(c.r, c.g, c.b)
+	}
+	if kind == colors256 && level < colors256 {
+		kind, index = colors16, nearestBasic(index)
+	}
+	base := 38
+	if bg {
+		base = 48
+	}
+	switch kind {
+	case colorsTrue:
+		return fmt.Sprintf("%d;2;%d;%d;%d", base, c.r, c.g, c.b)
+	case colors256:
+		return fmt.Sprintf("%d;5;%d", base, index)
+	}
+	// Basic colors are 30-37 and 90-97, backgrounds are 10 more
+	code := 30 + index
+	if index >= 8 {
+		code = 90 + index - 8
+	}
+	if bg {
+		code += 10
+	}
+	return strconv.Itoa(code)
+}
+
+// style is the way a part of the output is colored
+type style struct {
+	attrs  []int
+	fg, bg *termColor
+}
+
+// parseStyle parses a style like "hi-white on-red bold" or "#ff8700 underline"
+func parseStyle(spec string) (style, error) {
+	var s style
+	for _, word := range strings.Fields(spec) {
+		word = strings.ToLower(word)
+		if code, ok := colorAttrs[word]; ok {
+			s.attrs = append(s.attrs, code)
+			continue
+		}
+		bg := strings.HasPrefix(word, "on-")
+		c, err := parseColor(strings.TrimPrefix(word, "on-"))
+		if err != nil {
+			return style{}, err
+		}
+		if bg {
+			s.bg = &c
+		} else {
+			s.fg = &c
+		}
+	}
+	return s, nil
+}
+
+// parseColor parses a color name, a number of the 256 color palette or #rrggbb
+func parseColor(word string) (termColor, error) {
+	if strings.HasPrefix(word, "#") && len(word) == 7 {
+		rgb, err := strconv.ParseUint(word[1:], 16, 32)
+		if err == nil {
+			return termColor{kind: colorsTrue, r: int(rgb >> 16), g: int(rgb >> 8 & 0xff), b: int(rgb & 0xff)}, nil
+		}
+	}
+	if n, err := strconv.Atoi(word); err == nil && n >= 0 && n <= 255 {
+		return termColor{kind: colors256, index: n}, nil
+	}
+	name, bright := strings.TrimPrefix(word, "hi-"), strings.HasPrefix(word, "hi-")
+	for i, c := range colorNames {
+		if c == name {
+			if bright {
+				i += 8
+			}
+			return termColor{kind: colors16, index: i}, nil
+		}
+	}
+	return termColor{}, fmt.Errorf("unknown color or attribute %q", word)
+}
+
+// sequence returns the SGR sequence of the style for the given number of colors
+func (s style) sequence(level int) string {
+	var codes []string
+	for _, a := range s.attrs {
+		codes = append(codes, strconv.Itoa(a))
+	}
+	if s.fg != nil {
+		codes = append(codes, s.fg.codes(level, false))
+	}
+	if s.bg != nil {
+		codes = append(codes, s.bg.codes(level, true))
+	}
+	return strings.Join(codes, ";")
+}
+
+// sprint colors the text unless colors are disabled
+func (s style) sprint(a ...interface{}) string {
+	text := fmt.Sprint(a...)
+	if color.NoColor {
+		return text
+	}
+	seq := s.sequence(colorLevel)
+	if seq == "" {
+		return text
+	}
+	return "\x1b[" + seq + "m" + text + "\x1b[0m"
+}
+
+// tableColors returns the style as the codes used by the tables
+func (s style) tableColors() tablewriter.Colors {
+	var codes tablewriter.Colors
+	for _, part := range strings.Split(s.sequence(colorLevel), ";") {
+		if n, err := strconv.Atoi(part); err == nil {
+			codes = append(codes, n)
+		}
+	}
+	return codes
+}
+
+// theme holds the styles of every part of the output
+type theme struct {
+	styles map[string]style
+	// Lists of styles used in turn
+	lists map[string][]style
+}
+
+// get returns the style of a part of the output
+func (t *theme) get(key string) style {
+	return t.styles[key]
+}
+
+// list returns the n-th style of a list, starting again at the end of the list
+func (t *theme) list(key string, n int) style {
+	styles := t.lists[key]
+	if len(styles) == 0 {
+		return style{}
+	}
+	return styles[n%len(styles)]
+}
+
+// newTheme builds a built-in theme with the given overrides
+func newTheme(name string, overrides map[string]string) (*theme, error) {
+	specs, ok := themes[name]
+	if !ok {
+		return nil, fmt.Errorf("Error! Accepted themes are: %s", strings.Join(themeNames(), ", "))
+	}
+	t := &theme{styles: make(map[string]style), lists: make(map[string][]style)}
+	for _, key := range themeKeys {
+		spec := specs[key]
+		fromConfig := false
+		if v, ok := overrides[key]; ok {
+			spec, fromConfig = v, true
+		}
+		var parts []string
+		if key == "terms" || key == "tags" {
+			parts = strings.Split(spec, ",")
+		} else {
+			parts = []string{spec}
+		}
+		for _, part := range parts {
+			s, err := parseStyle(part)
+			if err != nil {
+				if fromConfig {
+					return nil, fmt.Errorf("Error! Invalid color %s in config: %v", key, err)
+				}
+				return nil, err
+			}
+			if key == "terms" || key == "tags" {
+				t.lists[key] = append(t.lists[key], s)
+			} else {
+				t.styles[key] = s
+			}
+		}
+	}
+	for key := range overrides {
+		if !stringInSlice(key, themeKeys) {
+			return nil, fmt.Errorf("Error! Unknown color %s in config. Accepted colors are: %s", key, strings.Join(themeKeys, ", "))
+		}
+	}
+	return t, nil
+}
+
+// mustTheme builds a built-in theme that is known to be valid
+func mustTheme(name string, overrides map[string]string) *theme {
+	t, err := newTheme(name, overrides)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// themeNames returns the names of the built-in themes in alphabetical order
+func themeNames() []string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setTheme switches the colors of the output to a built-in theme and the config overrides
+func setTheme(name string) {
+	if name == "" {
+		name = "dark"
+	}
+	t, err := newTheme(name, colorOverrides)
+	if err != nil {
+		exitWithError(err.Error())
+	}
+	currentTheme = t
+}
+
+// forceBasicColors turns colors on with the 16 basic colors only
+// The HTML outputs turn these colors into CSS classes
+// It returns a function restoring the previous settings
+func forceBasicColors() func() {
+	noColor, level := color.NoColor, colorLevel
+	color.NoColor, colorLevel = false, colors16
+	return func() { color.NoColor, colorLevel = noColor, level }
+}
+
+// rgbTo256 finds the closest color of the 256 color palette
+func rgbTo256(r, g, b int) int {
+	cube := func(v int) int {
+		best := 0
+		for i, l := range cubeLevels {
+			if abs(l-v) < abs(cubeLevels[best]-v) {
+				best = i
+			}
+		}
+		return best
+	}
+	ri, gi, bi := cube(r), cube(g), cube(b)
+	index := 16 + 36*ri + 6*gi + bi
+	// Grays are closer in the gray ramp
+	gray := (r + g + b) / 3
+	grayIndex := 232 + (gray-8+5)/10
+	if gray < 8 {
+		grayIndex = 232
+	} else if grayIndex > 255 {
+		grayIndex = 255
+	}
+	if colorDistance(paletteRGB(grayIndex), [3]int{r, g, b}) < colorDistance(paletteRGB(index), [3]int{r, g, b}) {
+		return grayIndex
+	}
+	return index
+}
+
+// nearestBasic finds the closest basic color of a color of the 256 color palette
+func nearestBasic(index int) int {
+	if index < 16 {
+		return index
+	}
+	rgb := paletteRGB(index)
+	best := 0
+	for i := range basicRGB {
+		if colorDistance(basicRGB[i], rgb) < colorDistance(basicRGB[best], rgb) {
+			best = i
+		}
+	}
+	return best
+}
+
+// paletteRGB returns the RGB value of a color of the 256 color palette
+func paletteRGB(index int) [3]int {
+	switch {
+	case index < 16:
+		return basicRGB[index]
+	case index < 232:
+		index -= 16
+		return [3]int{cubeLevels[index/36], cubeLevels[index/6%6], cubeLevels[index%6]}
+	}
+	gray := 8 + 10*(index-232)
+	return [3]int{gray, gray, gray}
+}
+
+// colorDistance returns the squared distance between 2 colors
+func colorDistance(a, b [3]int) int {
+	var d int
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return d
+}
+
+// abs returns the absolute value of an integer
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// newTable returns a table with the look shared by all tables
+func newTable(w io.Writer) *tablewriter.Table {
+	table := tablewriter.NewWriter(w)
+
+	table.SetRowLine(true)
+	table.SetCenterSeparator("+")
+	table.SetColumnSeparator("|")
+	table.SetRowSeparator("-")
+	return table
+}
+
+// setTableHeader sets the header of a table colored by the theme
+// Options changing the size of the cells must be set before
+func setTableHeader(table *tablewriter.Table, header []string) {
+	table.SetHeader(header)
+	if !color.NoColor {
+		if codes := currentTheme.get("table.header").tableColors(); len(codes) > 0 {
+			colors := make([]tablewriter.Colors, len(header))
+			for i := range colors {
+				colors[i] = codes
+			}
+			table.SetHeaderColor(colors...)
+		}
+	}
+}
+
+// Color functions to help display meaningful input
+// They follow the current theme
+func success(a ...interface{}) string { return currentTheme.get("match").sprint(a...) }
+func fail(a ...interface{}) string    { return currentTheme.get("error").sprint(a...) }
+func alert(a ...interface{}) string   { return currentTheme.get("alert").sprint(a...) }
+func info(a ...interface{}) string    { return currentTheme.get("info").sprint(a...) }
+
+// Log levels colored in the output and the key of their style
+var levelStyles = map[string]string{
+	"FATAL":    "level.error",
+	"PANIC":    "level.error",
+	"CRITICAL": "level.error",
+	"CRIT":     "level.error",
+	"ERROR":    "level.error",
+	"ERR":      "level.error",
+	"WARNING":  "level.warn",
+	"WARN":     "level.warn",
+	"NOTICE":   "level.info",
+	"INFO":     "level.info",
+	"DEBUG":    "level.debug",
+	"TRACE":    "level.debug",
+}
+
+// Log levels are upper case words so they are not mixed up with regular words
+var levelReg = regexp.MustCompile(`\b(?:FATAL|PANIC|CRITICAL|CRIT|ERROR|ERR|WARNING|WARN|NOTICE|INFO|DEBUG|TRACE)\b`)
+
+// colorSpan is a part of a text colored with a style
+type colorSpan struct {
+	start, end int
+	style      style
+}
+
+// levelSpans finds the log levels of a text
+func levelSpans(text string) []colorSpan {
+	var spans []colorSpan
+	for _, m := range levelReg.FindAllStringIndex(text, -1) {
+		spans = append(spans, colorSpan{m[0], m[1], currentTheme.get(levelStyles[text[m[0]:m[1]]])})
+	}
+	return spans
+}
+
+// paintSpans colors the spans of a text
+// When spans overlap the last one wins
+func paintSpans(text string, spans []colorSpan) string {
+	if len(spans) == 0 || color.NoColor {
+		return text
+	}
+	// The span coloring every byte, 0 means none
+	owner := make([]int, len(text))
+	for i, s := range spans {
+		for j := s.start; j < s.end; j++ {
+			owner[j] = i + 1
+		}
+	}
+	var b strings.Builder
+	for start := 0; start < len(text); {
+		end := start + 1
+		for end < len(text) && owner[end] == owner[start] {
+			end++
+		}
+		if owner[start] == 0 {
+			b.WriteString(text[start:end])
+		} else {
+			b.WriteString(spans[owner[start]-1].style.sprint(text[start:end]))
+		}
+		start = end
+	}
+	return b.String()
+}
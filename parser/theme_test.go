@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// TestParseStyle tests the style specs of the themes
+func TestParseStyle(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+		err  bool
+	}{
+		{"bold", "1", false},
+		{"red", "31", false},
+		{"hi-white on-red bold", "1;97;41", false},
+		{"208 underline", "4;38;5;208", false},
+		{"#ff8700", "38;2;255;135;0", false},
+		{"on-#000000", "48;2;0;0;0", false},
+		{"", "", false},
+		{"purple", "", true},
+		{"256", "", true},
+		{"#ff87", "", true},
+	}
+	for _, tt := range tests {
+		s, err := parseStyle(tt.spec)
+		if (err != nil) != tt.err {
+			t.Errorf("parseStyle(%q) error = %v, want error %v", tt.spec, err, tt.err)
+			continue
+		}
+		if got := s.sequence(colorsTrue); got != tt.want {
+			t.Errorf("parseStyle(%q).sequence() = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+// TestSequenceLevels tests that colors are downgraded to what the terminal supports
+func TestSequenceLevels(t *testing.T) {
+	tests := []struct {
+		spec  string
+		level int
+		want  string
+	}{
+		{"#ff8700", colorsTrue, "38;2;255;135;0"},
+		{"#ff8700", colors256, "38;5;208"},
+		{"#ff8700", colors16, "33"},
+		{"#000000", colors256, "38;5;16"},
+		{"#808080", colors256, "38;5;244"},
+		{"196", colors16, "91"},
+		{"4", colors16, "34"},
+		{"on-21", colors16, "44"},
+		{"hi-green", colors16, "92"},
+	}
+	for _, tt := range tests {
+		s, err := parseStyle(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.sequence(tt.level); got != tt.want {
+			t.Errorf("parseStyle(%q).sequence(%d) = %q, want %q", tt.spec, tt.level, got, tt.want)
+		}
+	}
+}
+
+// TestNewTheme tests the built-in themes and the overrides of the config files
+func TestNewTheme(t *testing.T) {
+	for _, name := range themeNames() {
+		if _, err := newTheme(name, nil); err != nil {
+			t.Errorf("newTheme(%q) error = %v", name, err)
+		}
+	}
+	th, err := newTheme("light", map[string]string{"level.error": "#ff0000", "terms": "red, green"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := th.get("level.error").sequence(colorsTrue); got != "38;2;255;0;0" {
+		t.Errorf("level.error = %q, want %q", got, "38;2;255;0;0")
+	}
+	if got := th.list("terms", 3).sequence(colorsTrue); got != "32" {
+		t.Errorf("terms[3] = %q, want %q", got, "32")
+	}
+	errs := []struct {
+		name      string
+		overrides map[string]string
+	}{
+		{"neon", nil},
+		{"dark", map[string]string{"lvl": "red"}},
+		{"dark", map[string]string{"match": "purple"}},
+		{"dark", map[string]string{"tags": "red, nope"}},
+	}
+	for _, tt := range errs {
+		if _, err := newTheme(tt.name, tt.overrides); err == nil {
+			t.Errorf("newTheme(%q, %v) expected an error", tt.name, tt.overrides)
+		}
+	}
+}
+
+// TestPaintSpans tests coloring overlapping parts of a text
+func TestPaintSpans(t *testing.T) {
+	noColor := color.NoColor
+	defer func() { color.NoColor = noColor }()
+	color.NoColor = false
+	red, _ := parseStyle("red")
+	bold, _ := parseStyle("bold")
+	tests := []struct {
+		text  string
+		spans []colorSpan
+		want  string
+	}{
+		{"plain", nil, "plain"},
+		{"an ERROR here", []colorSpan{{3, 8, red}}, "an \x1b[31mERROR\x1b[0m here"},
+		{"abcdef", []colorSpan{{0, 4, red}, {2, 6, bold}}, "\x1b[31mab\x1b[0m\x1b[1mcdef\x1b[0m"},
+		{"abcdef", []colorSpan{{0, 6, red}, {2, 3, bold}}, "\x1b[31mab\x1b[0m\x1b[1mc\x1b[0m\x1b[31mdef\x1b[0m"},
+	}
+	for _, tt := range tests {
+		if got := paintSpans(tt.text, tt.spans); got != tt.want {
+			t.Errorf("paintSpans(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+	color.NoColor = true
+	if got := paintSpans("abc", []colorSpan{{0, 3, red}}); got != "abc" {
+		t.Errorf("paintSpans() with colors disabled = %q, want %q", got, "abc")
+	}
+}
@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// timeFormat describes a timestamp format that can appear in a log line
+type timeFormat struct {
+	// Finds the timestamp inside the line
+	reg *regexp.Regexp
+	// Layouts tried in order to parse the timestamp
+	layouts []string
+}
+
+// Known timestamp formats, from the most to the least specific
+var timeFormats = []timeFormat{
+	// ISO 8601 / RFC 3339 and the variants used by most logging libraries
+	// e.g. 2019-12-10T15:04:05.123Z, 2019-12-10 15:04:05,123 +0200
+	{
+		reg: regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?: ?(?:Z|[+-]\d{2}:?\d{2}))?`),
+		layouts: []string{
+			"2006-01-02T15:04:05Z07:00",
+			"2006-01-02T15:04:05 Z07:00",
+			"2006-01-02T15:04:05Z0700",
+			"2006-01-02T15:04:05 Z0700",
+			"2006-01-02T15:04:05",
+		},
+	},
+	// Common and combined log format used by Apache and Nginx
+	// e.g. 10/Dec/2019:15:04:05 +0200
+	{
+		reg:     regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`),
+		layouts: []string{"02/Jan/2006:15:04:05 -0700"},
+	},
+	// Syslog format which has no year
+	// e.g. Dec 10 15:04:05
+	{
+		reg:     regexp.MustCompile(`[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}`),
+		layouts: []string{"Jan _2 15:04:05"},
+	},
+}
+
+// parseTimestamp extracts the first timestamp found in a line
+// The second value reports whether a timestamp was found
+func parseTimestamp(line string) (time.Time, bool) {
+	for _, tf := range timeFormats {
+		m := tf.reg.FindString(line)
+		if m == "" {
+			continue
+		}
+		// Normalize the separators to keep the number of layouts small
+		m = strings.Replace(m, ",", ".", 1)
+		if len(m) > 10 && m[10] == ' ' && m[4] == '-' {
+			m = m[:10] + "T" + m[11:]
+		}
+		for _, layout := range tf.layouts {
+			t, err := time.ParseInLocation(layout, m, time.Local)
+			if err != nil {
+				continue
+			}
+			// Timestamps without a year are considered to be from the last 12 months
+			if t.Year() == 0 {
+				now := time.Now()
+				t = t.AddDate(now.Year(), 0, 0)
+				if t.After(now.AddDate(0, 0, 1)) {
+					t = t.AddDate(-1, 0, 0)
+				}
+			}
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseTimestamp tests if timestamps are found
+// inside the most common log line formats
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		line  string
+		want  time.Time
+		found bool
+	}{
+		{
+			"2019-12-10T15:04:05Z ERROR upstream 502",
+			time.Date(2019, 12, 10, 15, 4, 5, 0, time.UTC),
+			true,
+		},
+		{
+			"2019-12-10 15:04:05,250 +0200 INFO started",
+			time.Date(2019, 12, 10, 13, 4, 5, 250000000, time.UTC),
+			true,
+		},
+		{
+			`{"time":"2019-12-10T15:04:05.5+01:00","level":"warn"}`,
+			time.Date(2019, 12, 10, 14, 4, 5, 500000000, time.UTC),
+			true,
+		},
+		{
+			`127.0.0.1 - - [10/Dec/2019:15:04:05 +0000] "GET / HTTP/1.1" 200 12`,
+			time.Date(2019, 12, 10, 15, 4, 5, 0, time.UTC),
+			true,
+		},
+		{
+			"no timestamp here",
+			time.Time{},
+			false,
+		},
+	}
+
+	for _, tc := range tests {
+		got, found := parseTimestamp(tc.line)
+		if found != tc.found {
+			t.Fatalf("With line %q: expected found %v; got %v", tc.line, tc.found, found)
+		}
+		if !got.Equal(tc.want) {
+			t.Fatalf("With line %q: expected %v; got %v", tc.line, tc.want, got)
+		}
+	}
+	// Syslog timestamps have no year so only the rest is checked
+	got, found := parseTimestamp("Dec 10 15:04:05 host app: crashed")
+	if !found || got.Month() != time.December || got.Day() != 10 || got.Hour() != 15 {
+		t.Fatalf("With syslog line: expected Dec 10 15:04:05; got %v", got)
+	}
+}
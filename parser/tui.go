@@ -0,0 +1,645 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Escape sequences used to drive the terminal
+const (
+	// Switch to the alternate screen and hide the cursor
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	// Show the cursor and go back to the main screen
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+	// Move the cursor to the given row and column
+	moveCursor = "\x1b[%d;%dH"
+	// Clear from the cursor to the end of the line
+	clearLine = "\x1b[K"
+	// Reverse video is used for the status bar and search highlights
+	reverseVideo = "\x1b[7m"
+	// Reset all text attributes
+	resetStyle = "\x1b[0m"
+)
+
+// Key codes for the special keys the navigator understands
+// Regular keys are represented by their rune value
+const (
+	keyUp = -(iota + 1)
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEscape
+	keyBackTab
+)
+
+// Maximum number of rendered pages kept in memory
+const maxCachedPages = 64
+
+// Time to wait after an escape byte to decide if it
+// starts a sequence or it is a lone Escape key press
+const escapeDelay = 25 * time.Millisecond
+
+// Matches ANSI escape sequences inside colored output
+var ansiReg = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// tui holds the state of the full screen navigator
+type tui struct {
+	p *Parser
+	// Stats for all files that can be browsed
+	fs []stats
+	// Index of the current file in fs
+	file int
+	// Index of the current page in the file offsets
+	page int
+	// First visible row inside the current page
+	top int
+	// Rendered rows for the pages of the current file
+	cache map[int][]string
+	// Show or hide the file sidebar
+	sidebar bool
+	// Current search term
+	search string
+	// Search term being typed when in search mode
+	input []rune
+	// Are we typing a search term?
+	searching bool
+	// Message displayed in the status bar
+	message string
+	// Incoming key presses
+	keys chan int
+	// Buffered terminal output
+	out *bufio.Writer
+}
+
+// runTUI starts the full screen navigator for the given file stats
+// It returns when the user quits
+func (p *Parser) runTUI(fs []stats) {
+	inFd := int(os.Stdin.Fd())
+	outFd := int(os.Stdout.Fd())
+	if !term.IsTerminal(inFd) || !term.IsTerminal(outFd) {
+		exitWithError("Error! The navigator needs an interactive terminal")
+	}
+	// The current page cannot be greater than the number of pages of the first file
+	if numPages := len(fs[0].offsets); p.page > numPages {
+		fmt.Printf("\n%s\n\n", fail(fmt.Sprintf("Error! Page number cannot be greater than %d", numPages)))
+		return
+	}
+	// Raw mode gives us every key press as soon as it is typed
+	state, err := term.MakeRaw(inFd)
+	if err != nil {
+		exitWithError(fmt.Sprintf("Cannot switch terminal to raw mode: %v", err))
+	}
+
+	t := newTUI(p, fs)
+	t.page = p.page - 1
+	t.out = bufio.NewWriter(os.Stdout)
+	go t.readKeys(bufio.NewReader(os.Stdin))
+
+	t.out.WriteString(enterScreen)
+	// The terminal is given back on errors too, they exit without running deferred calls
+	restore := func() {
+		t.out.WriteString(leaveScreen)
+		t.out.Flush()
+		term.Restore(inFd, state)
+	}
+	defer onExit(restore)()
+	defer restore()
+
+	for {
+		t.render()
+		key, ok := <-t.keys
+		if !ok || !t.handleKey(key) {
+			return
+		}
+	}
+}
+
+// newTUI returns a navigator at the top of the first file
+func newTUI(p *Parser, fs []stats) *tui {
+	return &tui{
+		p:       p,
+		fs:      fs,
+		cache:   make(map[int][]string),
+		sidebar: len(fs) > 1,
+		keys:    make(chan int),
+	}
+}
+
+// readKeys decodes the raw terminal input into key codes
+func (t *tui) readKeys(r *bufio.Reader) {
+	// Bytes are read in a separate goroutine so we can
+	// wait for the rest of an escape sequence with a timeout
+	bytesCh := make(chan byte)
+	go func() {
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				close(bytesCh)
+				return
+			}
+			bytesCh <- b
+		}
+	}()
+	// Returns the next byte or -1 if nothing came in time
+	next := func() int {
+		select {
+		case b, ok := <-bytesCh:
+			if !ok {
+				return -1
+			}
+			return int(b)
+		case <-time.After(escapeDelay):
+			return -1
+		}
+	}
+
+	for b := range bytesCh {
+		if b != 0x1b {
+			t.keys <- t.decodeRune(b, bytesCh)
+			continue
+		}
+		// A lone escape byte is the Escape key
+		if b1 := next(); b1 != '[' && b1 != 'O' {
+			t.keys <- keyEscape
+			continue
+		}
+		// Read the sequence until its final byte
+		var seq []byte
+		for {
+			c := next()
+			if c < 0 {
+				break
+			}
+			seq = append(seq, byte(c))
+			if c >= 0x40 && c <= 0x7e {
+				break
+			}
+		}
+		switch string(seq) {
+		case "A":
+			t.keys <- keyUp
+		case "B":
+			t.keys <- keyDown
+		case "5~":
+			t.keys <- keyPageUp
+		case "6~":
+			t.keys <- keyPageDown
+		case "H", "1~", "7~":
+			t.keys <- keyHome
+		case "F", "4~", "8~":
+			t.keys <- keyEnd
+		case "Z":
+			t.keys <- keyBackTab
+		}
+	}
+	close(t.keys)
+}
+
+// decodeRune reads the remaining bytes of an UTF-8 encoded key press
+func (t *tui) decodeRune(b byte, bytesCh chan byte) int {
+	if b < utf8.RuneSelf {
+		return int(b)
+	}
+	buf := []byte{b}
+	for !utf8.FullRune(buf) {
+		c, ok := <-bytesCh
+		if !ok {
+			break
+		}
+		buf = append(buf, c)
+	}
+	r, _ := utf8.DecodeRune(buf)
+	return int(r)
+}
+
+// handleKey applies a key press to the navigator state
+// It returns false when the user wants to quit
+func (t *tui) handleKey(key int) bool {
+	t.message = ""
+	// While typing a search term keys are part of the term
+	if t.searching {
+		switch key {
+		case '\r', '\n':
+			t.searching = false
+			t.search = string(t.input)
+			if t.search != "" {
+				t.findMatch(true, false)
+			}
+		case keyEscape, 3:
+			t.searching = false
+		case 127, 8:
+			if len(t.input) > 0 {
+				t.input = t.input[:len(t.input)-1]
+			}
+		default:
+			if key >= 32 {
+				t.input = append(t.input, rune(key))
+			}
+		}
+		return true
+	}
+
+	switch key {
+	case 'q', 3:
+		return false
+	case 'j', keyDown, '\r', '\n':
+		t.scroll(1)
+	case 'k', keyUp:
+		t.scroll(-1)
+	case ' ', 'f', 6, keyPageDown:
+		t.scroll(t.contentHeight())
+	case 'b', 2, keyPageUp:
+		t.scroll(-t.contentHeight())
+	case 'g', keyHome:
+		t.page, t.top = 0, 0
+	case 'G', keyEnd:
+		t.page = len(t.fs[t.file].offsets) - 1
+		t.top = len(t.rows(t.page)) - 1
+		t.scroll(-t.contentHeight() + 1)
+	case '/':
+		t.searching = true
+		t.input = nil
+	case 'n':
+		t.findMatch(true, true)
+	case 'N':
+		t.findMatch(false, true)
+	case '\t', ']':
+		t.switchFile(t.file + 1)
+	case keyBackTab, '[':
+		t.switchFile(t.file - 1)
+	case 's':
+		t.sidebar = !t.sidebar
+	}
+	return true
+}
+
+// switchFile moves the navigator to the top of another file
+func (t *tui) switchFile(file int) {
+	if file < 0 || file >= len(t.fs) {
+		return
+	}
+	t.file, t.page, t.top = file, 0, 0
+	t.cache = make(map[int][]string)
+}
+
+// scroll moves the view by the given number of rows
+// Negative values scroll up. Pages are crossed transparently
+func (t *tui) scroll(n int) {
+	last := len(t.fs[t.file].offsets) - 1
+	for ; n > 0; n-- {
+		if t.top+1 < len(t.rows(t.page)) {
+			t.top++
+		} else if t.page < last {
+			t.page++
+			t.top = 0
+		} else {
+			return
+		}
+	}
+	for ; n < 0; n++ {
+		if t.top > 0 {
+			t.top--
+		} else if t.page > 0 {
+			t.page--
+			t.top = len(t.rows(t.page)) - 1
+		} else {
+			return
+		}
+	}
+}
+
+// findMatch moves the view to the next (or previous) row containing the search term
+// When skip is true the current top row is not taken into account
+func (t *tui) findMatch(forward, skip bool) {
+	if t.search == "" {
+		t.message = "No search term. Press / to search"
+		return
+	}
+	numPages := len(t.fs[t.file].offsets)
+	page, row := t.page, t.top
+	step := 1
+	if !forward {
+		step = -1
+	}
+	if skip {
+		row += step
+	}
+	for page >= 0 && page < numPages {
+		rows := t.rows(page)
+		for ; row >= 0 && row < len(rows); row += step {
+			if strings.Contains(stripANSI(rows[row]), t.search) {
+				t.page, t.top = page, row
+				return
+			}
+		}
+		// Continue from the first row of the next page or the last row of the previous one
+		page += step
+		if forward {
+			row = 0
+		} else if page >= 0 {
+			row = len(t.rows(page)) - 1
+		}
+	}
+	t.message = fmt.Sprintf("Pattern not found: %s", t.search)
+}
+
+// rows returns the rendered rows for a page of the current file
+// A single line can span multiple rows when it is formatted (e.g. JSON)
+func (t *tui) rows(page int) []string {
+	if rows, ok := t.cache[page]; ok {
+		return rows
+	}
+	// Forget pages that are far away from the current one
+	if len(t.cache) >= maxCachedPages {
+		for k := range t.cache {
+			if k < t.page-maxCachedPages/2 || k > t.page+maxCachedPages/2 {
+				delete(t.cache, k)
+			}
+		}
+	}
+	stat := t.fs[t.file]
+	var rows []string
+	start := stat.firstLine(page + 1)
+	lines, err := t.p.readPage(stat.path, stat.offsets[page], start)
+	if err != nil {
+		fatal(err)
+	}
+	for _, line := range lines {
+		if t.p.excluded([]byte(line.text)) {
+			continue
+		}
+		rows = append(rows, strings.Split(t.p.numbered(line.number, t.p.collapsed(t.p.getOutput(line.number, line.text), line.count)), "\n")...)
+	}
+	// Every page has at least 1 row so the view always has something to show
+	if len(rows) == 0 {
+		rows = []string{""}
+	}
+	t.cache[page] = rows
+	return rows
+}
+
+// size returns the current terminal size
+func (t *tui) size() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 1 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// header returns the rows fixed above the file
+// The legend of the filter terms stays there while scrolling
+func (t *tui) header() []string {
+	if legend := t.p.legend(); legend != "" {
+		return []string{strings.TrimSuffix(legend, "\n")}
+	}
+	return nil
+}
+
+// contentHeight is the number of rows available to display the file
+func (t *tui) contentHeight() int {
+	_, height := t.size()
+	// The last row is reserved for the status bar
+	return height - 1 - len(t.header())
+}
+
+// sidebarWidth is the width of the file sidebar (0 when hidden)
+func (t *tui) sidebarWidth(width int) int {
+	if !t.sidebar {
+		return 0
+	}
+	w := width / 4
+	if w > 40 {
+		w = 40
+	}
+	return w
+}
+
+// render draws the whole screen
+func (t *tui) render() {
+	width, height := t.size()
+	side := t.sidebarWidth(width)
+	content := width - side
+	if side > 0 {
+		// Leave room for the separator
+		content--
+	}
+	// Draw the header on the first rows
+	header := t.header()
+	for i, h := range header {
+		fmt.Fprintf(t.out, moveCursor, i+1, 1)
+		t.out.WriteString(truncateANSI(h, width))
+		t.out.WriteString(resetStyle + clearLine)
+	}
+	lines := t.contentHeight()
+	// Gather the visible rows starting from the current position
+	var visible []string
+	page, row := t.page, t.top
+	for len(visible) < lines && page < len(t.fs[t.file].offsets) {
+		rows := t.rows(page)
+		for ; row < len(rows) && len(visible) < lines; row++ {
+			visible = append(visible, rows[row])
+		}
+		page++
+		row = 0
+	}
+
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(t.out, moveCursor, len(header)+i+1, 1)
+		if side > 0 {
+			t.out.WriteString(t.sidebarRow(i, side))
+			t.out.WriteString("│")
+		}
+		if i < len(visible) {
+			t.out.WriteString(truncateANSI(t.highlight(visible[i]), content))
+		} else {
+			t.out.WriteString("~")
+		}
+		t.out.WriteString(resetStyle + clearLine)
+	}
+	// Draw the status bar on the last row
+	fmt.Fprintf(t.out, moveCursor, height, 1)
+	t.out.WriteString(reverseVideo)
+	t.out.WriteString(truncateANSI(t.status(), width))
+	t.out.WriteString(clearLine + resetStyle)
+	t.out.Flush()
+}
+
+// highlight marks the search term inside a row
+func (t *tui) highlight(row string) string {
+	return highlightANSI(row, t.search)
+}
+
+// highlightANSI marks a term inside a colored row
+// The term is searched in the visible text so escape sequences are never broken
+// and a term split by a color change is still found
+func highlightANSI(row, term string) string {
+	if term == "" {
+		return row
+	}
+	// Visible text and the position of every visible byte in the row
+	var plain strings.Builder
+	var pos []int
+	for i := 0; i < len(row); {
+		if row[i] == 0x1b {
+			if loc := ansiReg.FindStringIndex(row[i:]); loc != nil && loc[0] == 0 {
+				i += loc[1]
+				continue
+			}
+		}
+		plain.WriteByte(row[i])
+		pos = append(pos, i)
+		i++
+	}
+	text := plain.String()
+	marked := make([]bool, len(text))
+	var found bool
+	for i := 0; ; {
+		j := strings.Index(text[i:], term)
+		if j < 0 {
+			break
+		}
+		for k := i + j; k < i+j+len(term); k++ {
+			marked[k] = true
+		}
+		i += j + len(term)
+		found = true
+	}
+	if !found {
+		return row
+	}
+	var b strings.Builder
+	// Colors of the row active at the current position
+	// They are given back after every highlight
+	var active string
+	k := 0
+	for i := 0; i < len(row); {
+		if k == len(pos) || i < pos[k] {
+			// An escape sequence
+			end := len(row)
+			if k < len(pos) {
+				end = pos[k]
+			}
+			for _, seq := range ansiReg.FindAllString(row[i:end], -1) {
+				if seq == resetStyle || seq == "\x1b[m" {
+					active = ""
+				} else {
+					active += seq
+				}
+				if k == 0 || k == len(pos) || !marked[k-1] || !marked[k] {
+					b.WriteString(seq)
+				}
+			}
+			i = end
+			continue
+		}
+		if marked[k] && (k == 0 || !marked[k-1]) {
+			b.WriteString(reverseVideo)
+		}
+		b.WriteByte(row[i])
+		if marked[k] && (k+1 == len(marked) || !marked[k+1]) {
+			b.WriteString(resetStyle + active)
+		}
+		i++
+		k++
+	}
+	return b.String()
+}
+
+// sidebarRow renders a row of the file sidebar
+func (t *tui) sidebarRow(i, width int) string {
+	// The first row is the title
+	if i == 0 {
+		return padRight(" Files", width)
+	}
+	if i > len(t.fs) {
+		return strings.Repeat(" ", width)
+	}
+	stat := t.fs[i-1]
+	text := fmt.Sprintf(" %d %s (%d/%d)", i, filepath.Base(stat.path), len(stat.offsets), stat.matches)
+	text = padRight(truncateANSI(text, width), width)
+	if i-1 == t.file {
+		return reverseVideo + text + resetStyle
+	}
+	return text
+}
+
+// status renders the status bar text
+func (t *tui) status() string {
+	if t.searching {
+		return "/" + string(t.input)
+	}
+	stat := t.fs[t.file]
+	parts := []string{
+		fmt.Sprintf(" File %d/%d: %s", t.file+1, len(t.fs), stat.path),
+		fmt.Sprintf("Page %d/%d", t.page+1, len(stat.offsets)),
+		fmt.Sprintf("Row %d", t.top+1),
+	}
+	if t.p.filter != "" {
+		parts = append(parts, fmt.Sprintf("Matches: %s", strconv.Itoa(stat.matches)))
+	}
+	if t.search != "" {
+		parts = append(parts, fmt.Sprintf("Search: %s (n/N)", t.search))
+	}
+	if t.message != "" {
+		parts = append(parts, t.message)
+	} else {
+		parts = append(parts, "q:quit /:search Tab:file")
+	}
+	return strings.Join(parts, " | ")
+}
+
+// stripANSI removes all color escape sequences from a string
+func stripANSI(s string) string {
+	return ansiReg.ReplaceAllString(s, "")
+}
+
+// truncateANSI cuts a string to the given visible width
+// keeping color escape sequences intact
+func truncateANSI(s string, width int) string {
+	var b strings.Builder
+	visible := 0
+	for len(s) > 0 {
+		if s[0] == 0x1b {
+			if loc := ansiReg.FindStringIndex(s); loc != nil && loc[0] == 0 {
+				b.WriteString(s[:loc[1]])
+				s = s[loc[1]:]
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		// Tabs and other control characters would break the layout
+		if r == '\t' {
+			r = ' '
+		} else if r < 32 {
+			continue
+		}
+		if visible >= width {
+			break
+		}
+		b.WriteRune(r)
+		visible++
+	}
+	return b.String()
+}
+
+// padRight fills a string with spaces up to the given visible width
+func padRight(s string, width int) string {
+	n := utf8.RuneCountInString(stripANSI(s))
+	if n >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-n)
+}
@@ -0,0 +1,208 @@
+package parser
+
+import "testing"
+
+// newTestTUI returns a navigator with pages already rendered
+// Every file is a list of pages and every page a list of rows
+func newTestTUI(files ...[][]string) *tui {
+	var fs []stats
+	for _, pages := range files {
+		fs = append(fs, stats{offsets: make([]int64, len(pages))})
+	}
+	t := newTUI(&Parser{}, fs)
+	for i, page := range files[0] {
+		t.cache[i] = page
+	}
+	return t
+}
+
+// TestHandleKey tests the key presses of the navigator
+func TestHandleKey(t *testing.T) {
+	pages := [][]string{{"a", "b", "c"}, {"d", "e"}, {"f"}}
+	tests := []struct {
+		name  string
+		keys  []int
+		page  int
+		top   int
+		file  int
+		quit  bool
+		input string
+	}{
+		{"down", []int{'j', keyDown}, 0, 2, 0, false, ""},
+		{"down crosses pages", []int{'j', 'j', 'j'}, 1, 0, 0, false, ""},
+		{"up at the top", []int{'k', keyUp}, 0, 0, 0, false, ""},
+		{"end", []int{'G'}, 0, 0, 0, false, ""},
+		{"home", []int{'j', 'j', 'j', 'g'}, 0, 0, 0, false, ""},
+		{"page down stops at the end", []int{' ', ' '}, 2, 0, 0, false, ""},
+		{"page up stops at the start", []int{'j', 'b'}, 0, 0, 0, false, ""},
+		{"quit", []int{'q'}, 0, 0, 0, true, ""},
+		{"ctrl-c", []int{3}, 0, 0, 0, true, ""},
+		{"next file", []int{'\t'}, 0, 0, 1, false, ""},
+		{"no file before the first", []int{'['}, 0, 0, 0, false, ""},
+		{"typing a search", []int{'/', 'q', 'x', 127, 'y'}, 0, 0, 0, false, "qy"},
+		{"search and next", []int{'/', 'e', '\r'}, 1, 1, 0, false, "e"},
+		{"cancel a search", []int{'/', 'e', keyEscape, 'j'}, 0, 1, 0, false, "e"},
+	}
+	for _, tt := range tests {
+		tui := newTestTUI(pages, [][]string{{"other"}})
+		quit := false
+		for _, key := range tt.keys {
+			if !tui.handleKey(key) {
+				quit = true
+				break
+			}
+		}
+		if quit != tt.quit || tui.page != tt.page || tui.top != tt.top || tui.file != tt.file || string(tui.input) != tt.input {
+			t.Errorf("%s: got page %d, top %d, file %d, quit %v, input %q, want page %d, top %d, file %d, quit %v, input %q",
+				tt.name, tui.page, tui.top, tui.file, quit, string(tui.input), tt.page, tt.top, tt.file, tt.quit, tt.input)
+		}
+	}
+}
+
+// TestScroll tests that scrolling never goes past the first or the last row
+func TestScroll(t *testing.T) {
+	pages := [][]string{{"a", "b"}, {"c"}, {"d", "e", "f"}}
+	tests := []struct {
+		n    []int
+		page int
+		top  int
+	}{
+		{[]int{1}, 0, 1},
+		{[]int{2}, 1, 0},
+		{[]int{5}, 2, 2},
+		{[]int{100}, 2, 2},
+		{[]int{100, -1}, 2, 1},
+		{[]int{100, -3}, 1, 0},
+		{[]int{100, -100}, 0, 0},
+		{[]int{-1}, 0, 0},
+	}
+	for _, tt := range tests {
+		tui := newTestTUI(pages)
+		for _, n := range tt.n {
+			tui.scroll(n)
+		}
+		if tui.page != tt.page || tui.top != tt.top {
+			t.Errorf("scroll(%v) = page %d, top %d, want page %d, top %d", tt.n, tui.page, tui.top, tt.page, tt.top)
+		}
+	}
+}
+
+// TestFindMatch tests searching forward and backward through the pages
+func TestFindMatch(t *testing.T) {
+	pages := [][]string{{"x", "\x1b[31merr\x1b[0mor one"}, {"ok"}, {"e\x1b[1mrror\x1b[0m two", "x"}}
+	tests := []struct {
+		name     string
+		search   string
+		start    [2]int
+		forward  bool
+		skip     bool
+		want     [2]int
+		notFound bool
+	}{
+		{"first match", "error", [2]int{0, 0}, true, false, [2]int{0, 1}, false},
+		{"current row", "error", [2]int{0, 1}, true, false, [2]int{0, 1}, false},
+		{"next match", "error", [2]int{0, 1}, true, true, [2]int{2, 0}, false},
+		{"previous match", "error", [2]int{2, 1}, false, true, [2]int{2, 0}, false},
+		{"previous page", "error", [2]int{2, 0}, false, true, [2]int{0, 1}, false},
+		{"escape codes are not matched", "31m", [2]int{0, 0}, true, false, [2]int{0, 0}, true},
+		{"not found", "missing", [2]int{1, 0}, true, true, [2]int{1, 0}, true},
+	}
+	for _, tt := range tests {
+		tui := newTestTUI(pages)
+		tui.search = tt.search
+		tui.page, tui.top = tt.start[0], tt.start[1]
+		tui.findMatch(tt.forward, tt.skip)
+		if got := [2]int{tui.page, tui.top}; got != tt.want || (tui.message != "") != tt.notFound {
+			t.Errorf("%s: got %v with message %q, want %v", tt.name, got, tui.message, tt.want)
+		}
+	}
+}
+
+// TestTruncateANSI tests cutting colored text to a visible width
+func TestTruncateANSI(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"hello", 3, "hel"},
+		{"hi", 5, "hi"},
+		{"\x1b[31mred\x1b[0m text", 4, "\x1b[31mred\x1b[0m "},
+		{"\x1b[31mred\x1b[0m", 0, "\x1b[31m"},
+		{"a\tb", 3, "a b"},
+		{"a\rb", 2, "ab"},
+		{"héllo", 2, "hé"},
+	}
+	for _, tt := range tests {
+		if got := truncateANSI(tt.s, tt.width); got != tt.want {
+			t.Errorf("truncateANSI(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+// TestHighlightANSI tests marking the search term in colored rows
+func TestHighlightANSI(t *testing.T) {
+	tests := []struct {
+		row  string
+		term string
+		want string
+	}{
+		{"plain row", "", "plain row"},
+		{"plain row", "row", "plain \x1b[7mrow\x1b[0m"},
+		{"\x1b[31mred\x1b[0m", "31", "\x1b[31mred\x1b[0m"},
+		{"\x1b[31mred\x1b[0m", "m", "\x1b[31mred\x1b[0m"},
+		{"\x1b[31mred text\x1b[0m", "d t", "\x1b[31mre\x1b[7md t\x1b[0m\x1b[31mext\x1b[0m"},
+		{"e\x1b[1mrror\x1b[0m", "error", "\x1b[7merror\x1b[0m\x1b[1m\x1b[0m"},
+		{"ab ab", "ab", "\x1b[7mab\x1b[0m \x1b[7mab\x1b[0m"},
+	}
+	for _, tt := range tests {
+		if got := highlightANSI(tt.row, tt.term); got != tt.want {
+			t.Errorf("highlightANSI(%q, %q) = %q, want %q", tt.row, tt.term, got, tt.want)
+		}
+	}
+}
+
+// TestExitHooks tests that the hooks restoring the terminal run in reverse order
+func TestExitHooks(t *testing.T) {
+	var calls []int
+	onExit(func() { calls = append(calls, 1) })
+	remove := onExit(func() { calls = append(calls, 2) })
+	onExit(func() { calls = append(calls, 3) })()
+	runExitHooks()
+	if len(calls) != 2 || calls[0] != 2 || calls[1] != 1 {
+		t.Errorf("exit hooks ran as %v, want [2 1]", calls)
+	}
+	// Removing a hook after they ran does nothing
+	remove()
+	if len(exitHooks) != 0 {
+		t.Errorf("%d exit hooks left, want 0", len(exitHooks))
+	}
+}
+
+// TestHeader tests if the legend of the filter terms is fixed above the file
+// Search cannot match it since it is not part of the rows
+func TestHeader(t *testing.T) {
+	defer forceBasicColors()()
+	tui := newTestTUI([][]string{{"ERROR a", "WARN b"}})
+	height := tui.contentHeight()
+	if len(tui.header()) != 0 {
+		t.Errorf("got header %q without terms", tui.header())
+	}
+	tui.p.withRegex = true
+	p, err := tui.p.withFilter("ERROR|WARN", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tui.p = p
+	if h := tui.header(); len(h) != 1 || stripANSI(h[0]) != "Terms: ERROR  WARN" {
+		t.Errorf("got header %q", h)
+	}
+	if got := tui.contentHeight(); got != height-1 {
+		t.Errorf("got content height %d, want %d", got, height-1)
+	}
+	tui.search = "Terms"
+	tui.findMatch(true, false)
+	if tui.page != 0 || tui.top != 0 || tui.message == "" {
+		t.Errorf("search matched the legend: page %d, top %d, message %q", tui.page, tui.top, tui.message)
+	}
+}
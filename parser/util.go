@@ -1,13 +1,10 @@
 package parser
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"strings"
 )
 
 // Checks if a slice of strings contains a given string
@@ -20,18 +17,52 @@ func stringInSlice(s string, list []string) bool {
 	return false
 }
 
-// Formats input as pretty JSON
-func formatJSON(text string) string {
-	var prettyJSON bytes.Buffer
-	if err := json.Indent(&prettyJSON, []byte(text), "", strings.Repeat(" ", 2)); err != nil {
-		log.Fatal("JSON parse error: ", err)
+// Exit status used when something goes wrong
+// Grep compatible modes use 2 to tell errors apart from "no match"
+var errorStatus = 1
+
+// Functions run before exiting on errors, e.g. to restore the terminal
+// Deferred calls are skipped by os.Exit so they cannot do this job
+var exitHooks []func()
+
+// onExit adds an exit hook
+// It returns a function removing the hook when it is no longer needed
+func onExit(hook func()) func() {
+	exitHooks = append(exitHooks, hook)
+	n := len(exitHooks)
+	return func() {
+		if len(exitHooks) >= n {
+			exitHooks = exitHooks[:n-1]
+		}
 	}
+}
 
-	return prettyJSON.String()
+// runExitHooks runs the exit hooks, the last added first
+// They run before the error is printed so the message ends up on the regular screen
+func runExitHooks() {
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		exitHooks[i]()
+	}
+	exitHooks = nil
 }
 
 // Exit with a nicely colored error message
 func exitWithError(s string) {
+	runExitHooks()
 	io.WriteString(os.Stderr, fmt.Sprintln(fail(s)))
-	os.Exit(1)
+	os.Exit(errorStatus)
+}
+
+// Exit with a log message, just like log.Fatal
+func fatal(v ...interface{}) {
+	runExitHooks()
+	log.Print(v...)
+	os.Exit(errorStatus)
+}
+
+// Exit with a formatted log message, just like log.Fatalf
+func fatalf(format string, v ...interface{}) {
+	runExitHooks()
+	log.Printf(format, v...)
+	os.Exit(errorStatus)
 }